@@ -0,0 +1,649 @@
+package konfig
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+)
+
+// bindTree assigns the values of a decoded key tree onto structValue. Keys are
+// matched to fields with the same tag and case-folding rules as the format's
+// native decoder, and every field the tree supplied is recorded in seen.
+// Fields are recorded with origin, completed with the line of their key when
+// lines maps dotted file key paths to the line they appear on.
+func bindTree(structValue reflect.Value, tree map[string]interface{}, format string, origin Origin, lines map[string]int, seen *origins) error {
+	b := binder{format: format, origin: origin, seen: seen, lines: lines, keys: map[string]string{}}
+	return b.bindStruct(structValue, tree, "")
+}
+
+type binder struct {
+	format string
+	origin Origin
+	seen   *origins
+	lines  map[string]int
+	// keys maps the Go path of each bound value to its dotted file key path.
+	keys map[string]string
+	// unknown collects keys that match no field, when not nil.
+	unknown *[]UnknownKey
+	// normalize falls back to matching keys with normalizeKey.
+	normalize bool
+	// deferred holds the file key paths of values referring to other keys;
+	// pending receives them by Go path as they are bound.
+	deferred map[string]bool
+	pending  map[string]pendingRef
+	// data is the JSON document being bound, used to apply keys in document
+	// order; nil otherwise.
+	data []byte
+}
+
+// enter notes that the value at path was read from key beneath parent.
+func (b *binder) enter(parent, path, key string) {
+	b.keys[path] = joinPath(b.keys[parent], key)
+}
+
+func (b *binder) originOf(path string) Origin {
+	origin := b.origin
+	origin.Line = b.lines[b.keys[path]]
+	return origin
+}
+
+func (b *binder) bindStruct(structValue reflect.Value, tree map[string]interface{}, path string) error {
+	fields := fileFields(structValue.Type(), b.format)
+	matched := map[string]string{}
+
+	for _, key := range b.keyOrder(fields, tree, path) {
+		field, ok, err := b.lookupField(fields, key)
+		if err != nil {
+			return atPath(path, err)
+		}
+		if ok && b.normalize {
+			if other, dup := matched[field.path]; dup {
+				return atPath(path, fmt.Errorf("keys %q and %q both match field %s", other, key, field.path))
+			}
+			matched[field.path] = key
+		}
+		if !ok {
+			if b.unknown != nil {
+				keyPath := joinPath(b.keys[path], key)
+				*b.unknown = append(*b.unknown, UnknownKey{
+					Source:     b.origin.Name,
+					Key:        keyPath,
+					Line:       b.lines[keyPath],
+					Suggestion: suggestField(fields, key),
+				})
+			}
+			continue
+		}
+
+		fieldPath := joinPath(path, field.path)
+		b.enter(path, fieldPath, key)
+		fieldValue, err := fieldByIndexAlloc(structValue, field.index)
+		if err != nil {
+			return atPath(fieldPath, err)
+		}
+
+		value := tree[key]
+		if field.quoted {
+			if value, err = unquoteValue(value, field.field.Type); err != nil {
+				return atPath(fieldPath, err)
+			}
+		}
+		if err := b.bind(fieldValue, value, fieldPath, field.field.Tag); err != nil {
+			return err
+		}
+
+		if tree[key] == nil {
+			origin := b.originOf(fieldPath)
+			origin.Unset = true
+			b.seen.clear(fieldPath, origin)
+			continue
+		}
+		b.seen.record(fieldPath, b.originOf(fieldPath))
+	}
+
+	return nil
+}
+
+// keyOrder returns the keys of tree in the order they are bound. When several
+// keys match one field the last one wins, as with the native decoders: JSON
+// keys follow the document, YAML keys stay sorted as sigs.k8s.io/yaml leaves
+// them, and TOML keys matching a field name exactly come last.
+func (b *binder) keyOrder(fields []fileField, tree map[string]interface{}, path string) []string {
+	keys := sortedKeys(tree)
+	if b.normalize || !sharedField(fields, keys) {
+		return keys
+	}
+
+	switch b.format {
+	case formatJSON:
+		if order := jsonObjectKeys(b.data, b.keys[path]); len(order) == len(keys) {
+			return order
+		}
+	case formatTOML:
+		exact := func(key string) bool {
+			field, ok := lookupFileField(fields, key)
+			return ok && field.name == key
+		}
+		sort.SliceStable(keys, func(i, j int) bool { return !exact(keys[i]) && exact(keys[j]) })
+	}
+	return keys
+}
+
+// sharedField reports whether more than one of keys matches the same field.
+func sharedField(fields []fileField, keys []string) bool {
+	matched := map[string]bool{}
+	for _, key := range keys {
+		field, ok := lookupFileField(fields, key)
+		if !ok {
+			continue
+		}
+		if matched[field.path] {
+			return true
+		}
+		matched[field.path] = true
+	}
+	return false
+}
+
+// lookupField finds the field key addresses, falling back to a normalized
+// match when enabled. A normalized key matching several fields is an error.
+func (b *binder) lookupField(fields []fileField, key string) (fileField, bool, error) {
+	if field, ok := lookupFileField(fields, key); ok || !b.normalize {
+		return field, ok, nil
+	}
+
+	var match fileField
+	found := false
+	for _, field := range fields {
+		if normalizeKey(field.name) != normalizeKey(key) {
+			continue
+		}
+		if found {
+			return fileField{}, false, fmt.Errorf("key %q matches both %s and %s", key, match.path, field.path)
+		}
+		match, found = field, true
+	}
+	return match, found, nil
+}
+
+// normalizeKey lower-cases name and drops the separators `_`, `-` and `.`.
+func normalizeKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '_', '-', '.':
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, name)
+}
+
+// bind assigns a single decoded value to dst. The tag of the enclosing struct
+// field is passed down so per-field options such as `layout` also apply to
+// slice and map elements.
+func (b *binder) bind(dst reflect.Value, value interface{}, path string, tag reflect.StructTag) error {
+	if value == nil {
+		// Unlike encoding/json, null resets any value, so a later layer can
+		// undo what an earlier one set.
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	if s, ok := value.(string); ok {
+		if key := b.keys[path]; b.deferred[key] {
+			b.pending[path] = pendingRef{key: key, origin: b.originOf(path)}
+		}
+		if handled, err := assignTimeString(dst, s, tag); handled {
+			if err != nil {
+				return atPath(path, err)
+			}
+			return nil
+		}
+	}
+
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return b.bind(dst.Elem(), value, path, tag)
+	}
+
+	if handled, err := b.bindUnmarshaler(dst, value); handled {
+		if err != nil {
+			return atPath(path, err)
+		}
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		if dst.NumMethod() != 0 {
+			return atPath(path, fmt.Errorf("unsupported interface type %s", dst.Type()))
+		}
+		dst.Set(reflect.ValueOf(plainValue(value)))
+		return nil
+	case reflect.Struct:
+		if t, ok := value.(time.Time); ok && dst.Type() == timeType {
+			dst.Set(reflect.ValueOf(t))
+			return nil
+		}
+		tree, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError(path, value, dst.Type())
+		}
+		strategy, _, err := parseMergeTag(tag)
+		if err != nil {
+			return atPath(path, err)
+		}
+		if strategy == mergeReplace {
+			dst.Set(reflect.Zero(dst.Type()))
+		}
+		return b.bindStruct(dst, tree, path)
+	case reflect.Map:
+		return b.bindMap(dst, value, path, tag)
+	case reflect.Slice:
+		if s, ok := value.(string); ok && dst.Type().Elem().Kind() == reflect.Uint8 {
+			data, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return atPath(path, err)
+			}
+			dst.SetBytes(data)
+			return nil
+		}
+		list, ok := asList(value)
+		if !ok {
+			return typeError(path, value, dst.Type())
+		}
+		return b.bindSlice(dst, list, path, tag)
+	case reflect.Array:
+		list, ok := asList(value)
+		if !ok {
+			return typeError(path, value, dst.Type())
+		}
+		for i := 0; i < dst.Len(); i++ {
+			if i >= len(list) {
+				dst.Index(i).Set(reflect.Zero(dst.Type().Elem()))
+				continue
+			}
+			itemPath := joinPath(path, strconv.Itoa(i))
+			b.enter(path, itemPath, strconv.Itoa(i))
+			if err := b.bind(dst.Index(i), list[i], itemPath, tag); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if b.format == formatYAML && dst.Kind() == reflect.String {
+		value = yamlStringValue(value)
+	}
+	if f, ok := value.(float64); ok && b.format != formatTOML {
+		// Decoders such as json.Unmarshal produce float64 for every number;
+		// read it like a JSON number so whole values fit integer fields.
+		value = json.Number(strconv.FormatFloat(f, 'f', -1, 64))
+	}
+	if err := assignScalar(dst, value); err != nil {
+		if errors.Is(err, errTypeMismatch) {
+			return typeError(path, value, dst.Type())
+		}
+		return atPath(path, err)
+	}
+	return nil
+}
+
+// bindUnmarshaler defers to the custom decoding hooks a type implements for
+// the current format, reporting whether one was used. Like assignText, the
+// hook runs on a fresh value that replaces dst once it succeeds.
+func (b *binder) bindUnmarshaler(dst reflect.Value, value interface{}) (bool, error) {
+	if !dst.CanSet() {
+		return false, nil
+	}
+	fresh := reflect.New(dst.Type())
+	target := fresh.Interface()
+
+	var (
+		handled bool
+		err     error
+	)
+	if b.format == formatTOML {
+		if u, ok := target.(toml.Unmarshaler); ok {
+			handled, err = true, u.UnmarshalTOML(value)
+		}
+	} else if u, ok := target.(json.Unmarshaler); ok {
+		var data []byte
+		if data, err = json.Marshal(value); err == nil {
+			err = u.UnmarshalJSON(data)
+		}
+		handled = true
+	}
+
+	if s, ok := value.(string); ok && !handled {
+		switch u := target.(type) {
+		case encoding.TextUnmarshaler:
+			handled, err = true, u.UnmarshalText([]byte(s))
+		case flag.Value:
+			handled, err = true, u.Set(s)
+		}
+	}
+
+	if handled && err == nil {
+		dst.Set(fresh.Elem())
+	}
+	return handled, err
+}
+
+// bindSlice combines list with the slice in dst according to the field's
+// merge strategy. Elements are recorded under their index in the result.
+func (b *binder) bindSlice(dst reflect.Value, list []interface{}, path string, tag reflect.StructTag) error {
+	strategy, keyName, err := parseMergeTag(tag)
+	if err != nil {
+		return atPath(path, err)
+	}
+	var key fileField
+	if strategy == mergeKeyed {
+		if key, err = mergeKeyField(dst.Type(), keyName, b.format); err != nil {
+			return atPath(path, err)
+		}
+	}
+
+	slice := reflect.MakeSlice(dst.Type(), 0, len(list))
+	if strategy == mergeAppend || strategy == mergeDeep || strategy == mergeKeyed {
+		slice = reflect.AppendSlice(slice, dst)
+	}
+
+	for i, item := range list {
+		index := -1
+		switch strategy {
+		case mergeDeep:
+			if i < slice.Len() {
+				index = i
+			}
+		case mergeKeyed:
+			index = b.keyedIndex(slice, item, key)
+		}
+		if index < 0 {
+			index = slice.Len()
+			slice = reflect.Append(slice, reflect.Zero(dst.Type().Elem()))
+		}
+
+		itemPath := joinPath(path, strconv.Itoa(index))
+		b.enter(path, itemPath, strconv.Itoa(i))
+		if err := b.bind(slice.Index(index), item, itemPath, tag); err != nil {
+			return err
+		}
+	}
+
+	dst.Set(slice)
+	return nil
+}
+
+func (b *binder) bindMap(dst reflect.Value, value interface{}, path string, tag reflect.StructTag) error {
+	tree, ok := value.(map[string]interface{})
+	if !ok {
+		return typeError(path, value, dst.Type())
+	}
+	strategy, _, err := parseMergeTag(tag)
+	if err != nil {
+		return atPath(path, err)
+	}
+	if strategy == mergeKeyed {
+		return atPath(path, fmt.Errorf("keyed merge needs a slice of structs, not %s", dst.Type()))
+	}
+
+	mapType := dst.Type()
+	if dst.IsNil() || strategy == mergeReplace {
+		dst.Set(reflect.MakeMapWithSize(mapType, len(tree)))
+	}
+
+	for _, key := range sortedKeys(tree) {
+		keyValue := reflect.New(mapType.Key()).Elem()
+		if err := assignMapKey(keyValue, key); err != nil {
+			return atPath(path, fmt.Errorf("key %q: %w", key, err))
+		}
+
+		elemPath := joinPath(path, key)
+		b.enter(path, elemPath, key)
+		if tree[key] == nil {
+			// null removes the entry instead of storing a zero value.
+			dst.SetMapIndex(keyValue, reflect.Value{})
+			origin := b.originOf(elemPath)
+			origin.Unset = true
+			b.seen.clear(elemPath, origin)
+			continue
+		}
+
+		elem := reflect.New(mapType.Elem()).Elem()
+		if existing := dst.MapIndex(keyValue); strategy == mergeDeep && existing.IsValid() {
+			elem.Set(existing)
+		}
+		if err := b.bind(elem, tree[key], elemPath, tag); err != nil {
+			return err
+		}
+		dst.SetMapIndex(keyValue, elem)
+	}
+
+	return nil
+}
+
+func assignMapKey(key reflect.Value, value string) error {
+	if u, ok := key.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+	return assignFromString(key, value)
+}
+
+var errTypeMismatch = errors.New("type mismatch")
+
+// yamlStringValue renders an unquoted YAML number or boolean the way
+// sigs.k8s.io/yaml does for a string field, so `version: 1.10` still loads
+// into a string. Other values are returned unchanged.
+func yamlStringValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		if _, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			return v.String()
+		}
+		if _, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return v.String()
+		}
+		if f, err := v.Float64(); err == nil {
+			return strconv.FormatFloat(f, 'g', -1, 32)
+		}
+	}
+	return value
+}
+
+// assignScalar stores a decoded string, bool or number in a field of the
+// matching kind, rejecting lossy or mismatched conversions the way the native
+// decoders do.
+func assignScalar(dst reflect.Value, value interface{}) error {
+	switch dst.Kind() {
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			return errTypeMismatch
+		}
+		dst.SetString(s)
+	case reflect.Bool:
+		v, ok := value.(bool)
+		if !ok {
+			return errTypeMismatch
+		}
+		dst.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch v := value.(type) {
+		case json.Number:
+			parsed, err := strconv.ParseInt(v.String(), 10, 64)
+			if err != nil {
+				return err
+			}
+			n = parsed
+		case int64:
+			n = v
+		default:
+			return errTypeMismatch
+		}
+		if dst.OverflowInt(n) {
+			return fmt.Errorf("value %d overflows %s", n, dst.Type())
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		switch v := value.(type) {
+		case json.Number:
+			parsed, err := strconv.ParseUint(v.String(), 10, 64)
+			if err != nil {
+				return err
+			}
+			n = parsed
+		case int64:
+			if v < 0 {
+				return fmt.Errorf("value %d overflows %s", v, dst.Type())
+			}
+			n = uint64(v)
+		default:
+			return errTypeMismatch
+		}
+		if dst.OverflowUint(n) {
+			return fmt.Errorf("value %d overflows %s", n, dst.Type())
+		}
+		dst.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch v := value.(type) {
+		case json.Number:
+			parsed, err := v.Float64()
+			if err != nil {
+				return err
+			}
+			f = parsed
+		case int64:
+			f = float64(v)
+		case float64:
+			f = v
+		default:
+			return errTypeMismatch
+		}
+		if dst.OverflowFloat(f) {
+			return fmt.Errorf("value %g overflows %s", f, dst.Type())
+		}
+		dst.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported kind %s", dst.Kind())
+	}
+
+	return nil
+}
+
+func typeError(path string, value interface{}, target reflect.Type) error {
+	return atPath(path, fmt.Errorf("cannot use %s as %s", describeValue(value), target))
+}
+
+// atPath prefixes err with the dotted path of the value being bound, if any.
+func atPath(path string, err error) error {
+	if path == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
+
+func describeValue(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case json.Number, int64, float64:
+		return "number"
+	case time.Time:
+		return "datetime"
+	case map[string]interface{}:
+		return "object"
+	}
+	if _, ok := asList(value); ok {
+		return "array"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// asList normalizes the array shapes produced by the decoders; TOML decodes
+// arrays of tables as []map[string]interface{}.
+func asList(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case []map[string]interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = item
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+// plainValue converts json.Number values to float64 so interface{} fields
+// receive the same types encoding/json would produce.
+func plainValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return v.String()
+		}
+		return f
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = plainValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = plainValue(item)
+		}
+		return out
+	}
+	return value
+}
+
+// fieldByIndexAlloc walks a promoted field index, allocating nil embedded
+// struct pointers along the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot allocate embedded pointer to unexported struct %s", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+func sortedKeys(tree map[string]interface{}) []string {
+	keys := make([]string, 0, len(tree))
+	for key := range tree {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,568 @@
+package konfig
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/netip"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"sigs.k8s.io/yaml"
+)
+
+type timeConfig struct {
+	Timeout  time.Duration
+	Interval *time.Duration
+	Started  time.Time
+	Birthday time.Time `layout:"2006-01-02"`
+	Zone     *time.Location
+}
+
+func TestLoadTimeValuesFromFiles(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]string{
+		"config.json": `{"Timeout":"30s","Interval":"1m30s","Started":"2024-05-01T10:00:00Z","Birthday":"1990-12-31","Zone":"Europe/Berlin"}`,
+		"config.yaml": "Timeout: 30s\nInterval: 1m30s\nStarted: \"2024-05-01T10:00:00Z\"\nBirthday: \"1990-12-31\"\nZone: Europe/Berlin\n",
+		"config.toml": "Timeout = \"30s\"\nInterval = \"1m30s\"\nStarted = 2024-05-01T10:00:00Z\nBirthday = \"1990-12-31\"\nZone = \"Europe/Berlin\"\n",
+	}
+
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(dir, name)
+			mustWrite(t, file, content)
+
+			var cfg timeConfig
+			if err := Load(&cfg, WithFiles(file)); err != nil {
+				t.Fatalf("Load returned error: %v", err)
+			}
+
+			if cfg.Timeout != 30*time.Second {
+				t.Fatalf("expected 30s timeout, got %v", cfg.Timeout)
+			}
+			if cfg.Interval == nil || *cfg.Interval != 90*time.Second {
+				t.Fatalf("expected 1m30s interval, got %v", cfg.Interval)
+			}
+			if !cfg.Started.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+				t.Fatalf("unexpected start time %v", cfg.Started)
+			}
+			if cfg.Birthday.Format("2006-01-02") != "1990-12-31" {
+				t.Fatalf("unexpected birthday %v", cfg.Birthday)
+			}
+			if cfg.Zone == nil || cfg.Zone.String() != "Europe/Berlin" {
+				t.Fatalf("unexpected zone %v", cfg.Zone)
+			}
+		})
+	}
+}
+
+type konfigTagConfig struct {
+	ListenAddr string `konfig:"listen_addr" json:"addr" toml:"addr"`
+	Database   struct {
+		MaxConns int `konfig:"max_conns,required"`
+	} `konfig:"database"`
+	Fallback string `json:"fallback_json" toml:"fallback_toml"`
+	Internal string `konfig:"-"`
+}
+
+func TestLoadKonfigTagNamesKeysInEveryFormat(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]string{
+		"config.json": `{"listen_addr":":8080","database":{"max_conns":10},"fallback_json":"json","Internal":"x"}`,
+		"config.yaml": "listen_addr: \":8080\"\ndatabase:\n  max_conns: 10\nfallback_json: json\nInternal: x\n",
+		"config.toml": "listen_addr = \":8080\"\nfallback_toml = \"json\"\nInternal = \"x\"\n\n[database]\nmax_conns = 10\n",
+	}
+
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(dir, name)
+			mustWrite(t, file, content)
+
+			var cfg konfigTagConfig
+			if err := Load(&cfg, WithFiles(file), WithStrict()); err == nil || !strings.Contains(err.Error(), "Internal") {
+				t.Fatalf("expected konfig:\"-\" field to be an unknown key, got %v", err)
+			}
+			if err := Load(&cfg, WithFiles(file)); err != nil {
+				t.Fatalf("Load returned error: %v", err)
+			}
+			if cfg.ListenAddr != ":8080" || cfg.Database.MaxConns != 10 || cfg.Fallback != "json" || cfg.Internal != "" {
+				t.Fatalf("unexpected config %+v", cfg)
+			}
+		})
+	}
+
+	t.Setenv("APP_LISTEN_ADDR", ":9090")
+	t.Setenv("APP_INTERNAL", "x")
+	var cfg konfigTagConfig
+	if err := Load(&cfg, WithFiles(filepath.Join(dir, "config.toml")), WithEnvPrefix("APP")); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.ListenAddr != ":9090" || cfg.Internal != "" {
+		t.Fatalf("expected env keys from konfig tags, got %+v", cfg)
+	}
+}
+
+func TestWithNormalizedKeys(t *testing.T) {
+	type pool struct {
+		MaxConns int
+		IdleTime string `toml:"idle_time"`
+	}
+	type cfg struct {
+		Pool pool `json:"pool" toml:"pool"`
+	}
+
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "app.yaml")
+	tomlFile := filepath.Join(dir, "old.toml")
+	jsonFile := filepath.Join(dir, "team.json")
+	mustWrite(t, yamlFile, "pool:\n  max_conns: 10\n  idle-time: 1m\n")
+	mustWrite(t, tomlFile, "[pool]\nmaxConns = 20\n")
+	mustWrite(t, jsonFile, `{"pool":{"Max-Conns":30,"idle.time":"2m"}}`)
+
+	for file, want := range map[string]pool{
+		yamlFile: {MaxConns: 10, IdleTime: "1m"},
+		tomlFile: {MaxConns: 20},
+		jsonFile: {MaxConns: 30, IdleTime: "2m"},
+	} {
+		var c cfg
+		if err := Load(&c, WithFiles(file), WithNormalizedKeys()); err != nil {
+			t.Fatalf("%s: Load returned error: %v", file, err)
+		}
+		if c.Pool != want {
+			t.Fatalf("%s: expected %+v, got %+v", file, want, c.Pool)
+		}
+	}
+
+	var c cfg
+	if err := Load(&c, WithFiles(yamlFile), WithDefaultsAsSource()); err != nil || c.Pool.MaxConns != 0 {
+		t.Fatalf("expected snake_case keys ignored without normalization, got %+v (%v)", c, err)
+	}
+
+	mustWrite(t, yamlFile, "pool:\n  max_conns: 10\n  maxConns: 20\n")
+	err := Load(&c, WithFiles(yamlFile), WithNormalizedKeys())
+	if err == nil || !strings.Contains(err.Error(), `Pool: keys "maxConns" and "max_conns" both match field MaxConns`) {
+		t.Fatalf("expected ambiguous keys error, got %v", err)
+	}
+}
+
+func TestWithNormalizedKeysAmbiguousFields(t *testing.T) {
+	type cfg struct {
+		MaxConns  int
+		Max_Conns int
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.json")
+	mustWrite(t, file, `{"max-conns":1}`)
+
+	var c cfg
+	err := Load(&c, WithSources(FileSource{Path: file}), WithNormalizedKeys())
+	if err == nil || !strings.Contains(err.Error(), `key "max-conns" matches both MaxConns and Max_Conns`) {
+		t.Fatalf("expected ambiguous fields error, got %v", err)
+	}
+}
+
+func TestLoadNullUnsetsAcrossLayers(t *testing.T) {
+	type tls struct {
+		Cert string `json:"cert" toml:"cert"`
+	}
+	type cfg struct {
+		Name    string            `json:"name" toml:"name"`
+		Port    int               `json:"port" toml:"port" konfig:",required"`
+		Timeout *int              `json:"timeout" toml:"timeout"`
+		TLS     *tls              `json:"tls" toml:"tls"`
+		Labels  map[string]string `json:"labels" toml:"labels"`
+	}
+
+	dir := t.TempDir()
+	base := filepath.Join(dir, "default.toml")
+	override := filepath.Join(dir, "override.yaml")
+	mustWrite(t, base, "name = \"svc\"\nport = 8080\ntimeout = 30\n\n[tls]\ncert = \"a.pem\"\n\n[labels]\nteam = \"core\"\ntier = \"web\"\n")
+	mustWrite(t, override, "timeout: null\ntls: null\nlabels:\n  tier: null\n")
+
+	var c cfg
+	var report Provenance
+	if err := Load(&c, WithFiles(base, override), WithProvenance(&report)); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Name != "svc" || c.Timeout != nil || c.TLS != nil || !reflect.DeepEqual(c.Labels, map[string]string{"team": "core"}) {
+		t.Fatalf("expected nulls to clear earlier values, got %+v", c)
+	}
+
+	for _, path := range []string{"Timeout", "TLS.Cert", "Labels.tier"} {
+		field, ok := report.Lookup(path)
+		if !ok || !field.Origin.Unset || field.Origin.Name != override {
+			t.Fatalf("expected %s recorded as unset by %s, got %+v", path, override, field)
+		}
+	}
+	if field, _ := report.Lookup("Timeout"); field.Origin.String() != override+":1 (unset)" || len(field.Overridden) != 1 {
+		t.Fatalf("expected unset to override the base file, got %+v", field)
+	}
+
+	mustWrite(t, override, "port: null\n")
+	var missing *MissingError
+	if err := Load(&c, WithFiles(base, override)); !errors.As(err, &missing) || missing.Fields[0].Path != "Port" {
+		t.Fatalf("expected unset required field to be missing, got %v", err)
+	}
+}
+
+func TestLoadDurationFromNumber(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.json")
+	mustWrite(t, file, `{"Timeout":1000000000}`)
+
+	var cfg timeConfig
+	if err := Load(&cfg, WithFiles(file)); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Timeout != time.Second {
+		t.Fatalf("expected nanosecond number to decode as 1s, got %v", cfg.Timeout)
+	}
+}
+
+func TestLoadTimeValueErrors(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]string{
+		`"Timeout":"soon"`:      `Timeout: time: invalid duration "soon"`,
+		`"Birthday":"31/12/90"`: `Birthday: parsing time "31/12/90"`,
+		`"Zone":"Mars/Olympus"`: `Zone: unknown time zone Mars/Olympus`,
+	}
+
+	for field, want := range cases {
+		file := filepath.Join(dir, "config.json")
+		mustWrite(t, file, "{"+field+"}")
+
+		var cfg timeConfig
+		err := Load(&cfg, WithFiles(file))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q, got %v", want, err)
+		}
+	}
+}
+
+func TestLoadJSONHelperParsesDurations(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.json")
+	mustWrite(t, file, `{"Timeout":"5s"}`)
+
+	var cfg timeConfig
+	if err := LoadJSON(file, &cfg); err != nil {
+		t.Fatalf("LoadJSON returned error: %v", err)
+	}
+	if cfg.Timeout != 5*time.Second {
+		t.Fatalf("expected 5s, got %v", cfg.Timeout)
+	}
+
+	var raw map[string]interface{}
+	if err := LoadJSON(file, &raw); err != nil || raw["Timeout"] != "5s" {
+		t.Fatalf("expected non-struct target to decode natively, got %v (%v)", raw, err)
+	}
+}
+
+type upperName string
+
+func (u *upperName) UnmarshalJSON(data []byte) error {
+	*u = upperName(strings.ToUpper(strings.Trim(string(data), `"`)))
+	return nil
+}
+
+type Embedded struct {
+	Region string `json:"region"`
+}
+
+func TestBindMatchesNativeDecoding(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	mustWrite(t, file, strings.Join([]string{
+		"name: svc",
+		"region: eu",
+		"addr: 10.0.0.1",
+		"big: 9007199254740993",
+		"ratio: 0.5",
+		"raw: {nested: [1, two]}",
+		"ports: [80, 443]",
+		"pair: [1, 2, 3]",
+		"hosts:",
+		"  - {name: a}",
+		"  - {name: b}",
+		"labels: {tier: web}",
+		"weights: {1: 0.5}",
+		"secret: aGVsbG8=",
+		"skipped: 1",
+		"cleared: null",
+	}, "\n"))
+
+	type host struct {
+		Name string `json:"name"`
+	}
+
+	type cfg struct {
+		*Embedded
+		Name    upperName           `json:"name"`
+		Addr    netip.Addr          `json:"addr"`
+		Big     int64               `json:"big"`
+		Ratio   float32             `json:"ratio"`
+		Raw     interface{}         `json:"raw"`
+		Ports   []uint16            `json:"ports"`
+		Pair    [2]int              `json:"pair"`
+		Hosts   []host              `json:"hosts"`
+		Labels  map[string]string   `json:"labels"`
+		Weights map[int]float64     `json:"weights"`
+		Secret  []byte              `json:"secret"`
+		Skipped string              `json:"-"`
+		Cleared map[string]struct{} `json:"cleared"`
+	}
+
+	c := cfg{Cleared: map[string]struct{}{"x": {}}}
+	if err := Load(&c, WithFiles(file)); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if c.Embedded == nil || c.Region != "eu" {
+		t.Fatalf("expected embedded pointer allocated, got %#v", c.Embedded)
+	}
+	if c.Name != "SVC" {
+		t.Fatalf("expected json.Unmarshaler to run, got %q", c.Name)
+	}
+	if c.Addr != netip.MustParseAddr("10.0.0.1") {
+		t.Fatalf("expected TextUnmarshaler to run, got %v", c.Addr)
+	}
+	if c.Big != 9007199254740993 {
+		t.Fatalf("expected integer precision preserved, got %d", c.Big)
+	}
+	if c.Ratio != 0.5 {
+		t.Fatalf("expected ratio 0.5, got %v", c.Ratio)
+	}
+	raw, ok := c.Raw.(map[string]interface{})
+	if !ok || raw["nested"].([]interface{})[0] != float64(1) {
+		t.Fatalf("expected interface to hold plain json values, got %#v", c.Raw)
+	}
+	if len(c.Ports) != 2 || c.Ports[1] != 443 {
+		t.Fatalf("unexpected ports %v", c.Ports)
+	}
+	if c.Pair != [2]int{1, 2} {
+		t.Fatalf("unexpected pair %v", c.Pair)
+	}
+	if len(c.Hosts) != 2 || c.Hosts[1].Name != "b" {
+		t.Fatalf("unexpected hosts %v", c.Hosts)
+	}
+	if c.Labels["tier"] != "web" || c.Weights[1] != 0.5 {
+		t.Fatalf("unexpected maps %v %v", c.Labels, c.Weights)
+	}
+	if string(c.Secret) != "hello" {
+		t.Fatalf("expected base64 bytes, got %q", c.Secret)
+	}
+	if c.Skipped != "" {
+		t.Fatalf("expected json:\"-\" field skipped, got %q", c.Skipped)
+	}
+	if c.Cleared != nil {
+		t.Fatalf("expected null to clear map, got %v", c.Cleared)
+	}
+}
+
+type nativeHost struct {
+	Name string `json:"name" toml:"name"`
+	Port int    `json:"port" toml:"port"`
+}
+
+type nativeConfig struct {
+	Embedded
+	Name    string `json:"name" toml:"name"`
+	Title   string
+	Quoted  int `json:"quoted,string" toml:"quoted"`
+	Ratio   float64
+	Enabled bool
+	Hosts   []nativeHost      `json:"hosts" toml:"hosts"`
+	Ports   []uint16          `json:"ports" toml:"ports"`
+	Labels  map[string]string `json:"labels" toml:"labels"`
+	Timeout *int              `json:"timeout" toml:"timeout"`
+	Extra   interface{}       `json:"extra" toml:"extra"`
+	Skipped string            `json:"-" toml:"-"`
+	Nested  struct {
+		Deep struct {
+			Value string
+		}
+	}
+}
+
+func TestLoadMatchesNativeDecoders(t *testing.T) {
+	native := map[string]func([]byte, interface{}) error{
+		".json": json.Unmarshal,
+		".yaml": func(data []byte, v interface{}) error { return yaml.Unmarshal(data, v) },
+		".toml": toml.Unmarshal,
+	}
+
+	cases := []struct {
+		name    string
+		ext     string
+		content string
+	}{
+		{"json", ".json", `{"region":"eu","name":"svc","TITLE":"t","quoted":"12","ratio":0.25,"enabled":true,"hosts":[{"name":"a","port":1},{"NAME":"b"}],"ports":[80,443],"labels":{"tier":"web"},"timeout":5,"extra":{"n":[1,"x"]},"Skipped":"no","nested":{"DEEP":{"value":"v"}},"unknown":1}`},
+		{"json case duplicates", ".json", `{"name":"a","Name":"b","title":"x","TITLE":"y"}`},
+		{"json case duplicates reversed", ".json", `{"Name":"b","name":"a"}`},
+		{"json repeated key", ".json", `{"Name":"b","name":"a","Name":"c"}`},
+		{"json nested case duplicates", ".json", `{"hosts":[{"NAME":"a","name":"b","Name":"c"}],"nested":{"deep":{"VALUE":"x","value":"y"},"Deep":{"Value":"z"}}}`},
+		{"json null", ".json", `{"timeout":null,"hosts":null,"extra":null}`},
+		{"yaml", ".yaml", "region: eu\nname: svc\ntitle: t\nquoted: \"12\"\nratio: 0.25\nenabled: true\nhosts:\n  - name: a\n    port: 1\nports: [80, 443]\nlabels:\n  tier: web\ntimeout: 5\nextra:\n  n: [1, x]\nnested:\n  deep:\n    value: v\n"},
+		{"yaml case duplicates", ".yaml", "name: a\nName: b\nTITLE: x\ntitle: y\n"},
+		{"yaml scalars", ".yaml", "name: 1.10\ntitle: yes\nlabels:\n  zip: 01234\n"},
+		{"toml", ".toml", "Region = \"eu\"\nname = \"svc\"\ntitle = \"t\"\nquoted = 12\nratio = 0.25\nenabled = true\nports = [80, 443]\ntimeout = 5\n\n[[hosts]]\nname = \"a\"\nport = 1\n\n[labels]\ntier = \"web\"\n\n[extra]\nn = [1, 2]\n\n[nested.deep]\nvalue = \"v\"\n"},
+	}
+
+	dir := t.TempDir()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(dir, "config"+tc.ext)
+			mustWrite(t, file, tc.content)
+
+			seed := func() nativeConfig {
+				timeout := 1
+				return nativeConfig{Title: "seed", Timeout: &timeout, Hosts: []nativeHost{{Name: "seed"}}}
+			}
+
+			want := seed()
+			if err := native[tc.ext]([]byte(tc.content), &want); err != nil {
+				t.Fatalf("native decoder returned error: %v", err)
+			}
+			got := seed()
+			if err := Load(&got, WithSources(FileSource{Path: file})); err != nil {
+				t.Fatalf("Load returned error: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("Load differs from the native decoder:\n got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+
+	// BurntSushi/toml applies such keys in map order; Load lets the exact
+	// match win every time.
+	file := filepath.Join(dir, "dup.toml")
+	mustWrite(t, file, "NAME = \"upper\"\nname = \"exact\"\nName = \"title\"\n")
+	var c nativeConfig
+	if err := Load(&c, WithSources(FileSource{Path: file})); err != nil || c.Name != "exact" {
+		t.Fatalf("expected the exact TOML key to win, got %q (%v)", c.Name, err)
+	}
+}
+
+func TestLoadYAMLScalarsIntoStrings(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	content := "version: 1.10\nzip: 01234\nenabled: yes\nbuild: 42\npi: 3.14159265358979\ntags: [1, true]\n"
+	mustWrite(t, file, content)
+
+	type cfg struct {
+		Version string
+		Zip     string
+		Enabled string
+		Build   *string
+		Pi      string
+		Tags    []string
+	}
+
+	var got cfg
+	if err := Load(&got, WithFiles(file)); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	var want cfg
+	if err := yaml.Unmarshal([]byte(content), &want); err != nil {
+		t.Fatalf("yaml.Unmarshal returned error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v like sigs.k8s.io/yaml, got %+v", want, got)
+	}
+}
+
+func TestLoadJSONStringOption(t *testing.T) {
+	type cfg struct {
+		Port  int      `json:"port,string"`
+		OK    *bool    `json:"ok,string"`
+		Name  string   `json:"name,string"`
+		Ratio float64  `json:"ratio,omitempty,string"`
+		Tags  []string `json:"tags,string"`
+	}
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"config.json": `{"port":"8080","ok":"true","name":"\"svc\"","ratio":"0.5","tags":["a"]}`,
+		"config.yaml": "port: \"8080\"\nok: \"true\"\nname: '\"svc\"'\nratio: \"0.5\"\ntags: [a]\n",
+	} {
+		file := filepath.Join(dir, name)
+		mustWrite(t, file, content)
+
+		var c cfg
+		if err := Load(&c, WithFiles(file)); err != nil {
+			t.Fatalf("%s: Load returned error: %v", name, err)
+		}
+		if c.Port != 8080 || c.OK == nil || !*c.OK || c.Name != "svc" || c.Ratio != 0.5 || c.Tags[0] != "a" {
+			t.Fatalf("%s: expected quoted values decoded, got %+v", name, c)
+		}
+	}
+
+	cases := map[string]string{
+		`{"port":8080}`:    "Port: invalid use of ,string struct tag, trying to unmarshal unquoted value into int",
+		`{"port":"x"}`:     `Port: invalid use of ,string struct tag, trying to unmarshal "x" into int`,
+		`{"name":"svc"}`:   `Name: invalid use of ,string struct tag, trying to unmarshal "svc" into string`,
+		`{"port":"\"1\""}`: `Port: invalid use of ,string struct tag`,
+	}
+	for content, want := range cases {
+		file := filepath.Join(dir, "config.json")
+		mustWrite(t, file, content)
+
+		var c cfg
+		if err := Load(&c, WithFiles(file)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %q, got %v", content, want, err)
+		}
+	}
+}
+
+func TestBindTypeErrors(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		content string
+		want    string
+	}{
+		{`{"Port":"eighty"}`, "Port: cannot use string as int"},
+		{`{"Port":1.5}`, "Port: strconv.ParseInt"},
+		{`{"Small":300}`, "Small: value 300 overflows int8"},
+		{`{"Count":-1}`, "Count: strconv.ParseUint"},
+		{`{"Name":true}`, "Name: cannot use bool as string"},
+		{`{"Nested":[1]}`, "Nested: cannot use array as struct"},
+		{`{"Tags":{"a":1}}`, "Tags: cannot use object as []string"},
+		{`{"Nested":{"Flag":"yes"}}`, "Nested.Flag: cannot use string as bool"},
+	}
+
+	type cfg struct {
+		Port   int
+		Small  int8
+		Count  uint
+		Name   string
+		Tags   []string
+		Nested struct {
+			Flag bool
+		}
+	}
+
+	for _, tc := range cases {
+		file := filepath.Join(dir, "config.json")
+		mustWrite(t, file, tc.content)
+
+		var c cfg
+		err := Load(&c, WithFiles(file))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected %q, got %v", tc.content, tc.want, err)
+		}
+	}
+}
+
+func TestDecodeTreeRejectsTrailingData(t *testing.T) {
+	if _, err := decodeTree(formatJSON, []byte(`{"a":1} {"b":2}`)); err == nil {
+		t.Fatalf("expected trailing data error")
+	}
+	if _, err := decodeTree(formatJSON, nil); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected unexpected EOF, got %v", err)
+	}
+}
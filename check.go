@@ -0,0 +1,108 @@
+package konfig
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// EnvCollision is an environment variable that more than one field reads.
+type EnvCollision struct {
+	Key string
+	// Paths are the dotted Go field paths reading Key, in declaration order.
+	Paths []string
+}
+
+// EnvCollisionError reports every environment key shared by several fields.
+type EnvCollisionError struct {
+	Collisions []EnvCollision
+}
+
+func (e *EnvCollisionError) Error() string {
+	parts := make([]string, len(e.Collisions))
+	for i, c := range e.Collisions {
+		parts[i] = c.Key + " is read by " + strings.Join(c.Paths, " and ")
+	}
+	return "konfig: colliding environment keys: " + strings.Join(parts, "; ")
+}
+
+// Check reports problems with the definition of config that do not depend on
+// any source, such as two fields reading the same environment variable under
+// the env prefix of opts. Load performs the same checks before reading
+// anything; Check lets tests catch them without a full load.
+func Check(config interface{}, opts ...Option) error {
+	structType := reflect.TypeOf(config)
+	for structType != nil && structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType == nil || structType.Kind() != reflect.Struct {
+		return errors.New("konfig: config must be a struct or a pointer to struct")
+	}
+
+	cfg := options{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	sources, err := cfg.pipeline()
+	if err != nil {
+		return err
+	}
+
+	return checkDefinition(structType, sources)
+}
+
+// checkDefinition runs the source-independent checks of Check.
+func checkDefinition(structType reflect.Type, sources []Source) error {
+	var collisions []EnvCollision
+	for _, source := range sources {
+		if env, ok := source.(EnvSource); ok {
+			collisions = append(collisions, envCollisions(structType, env.Prefix)...)
+		}
+	}
+
+	if len(collisions) > 0 {
+		return &EnvCollisionError{Collisions: collisions}
+	}
+	return nil
+}
+
+// envCollisions lists the keys that more than one field of structType reads,
+// including keys of plain fields that also address an element of an indexed
+// slice or map, such as APP_UPS_0_HOST for a field Ups0Host beside Ups.
+func envCollisions(structType reflect.Type, prefix string) []EnvCollision {
+	fields := envFields(structType, prefix, "")
+
+	paths := map[string][]string{}
+	var keys []string
+	for _, field := range fields {
+		if field.group || field.indexed {
+			continue
+		}
+		if _, ok := paths[field.key]; !ok {
+			keys = append(keys, field.key)
+			paths[field.key] = nil
+		}
+	}
+
+	for _, field := range fields {
+		switch {
+		case field.group:
+		case field.indexed:
+			for _, key := range keys {
+				if readsEnv([]envField{field}, key) {
+					paths[key] = append(paths[key], field.path)
+				}
+			}
+		default:
+			paths[field.key] = append(paths[field.key], field.path)
+		}
+	}
+
+	var collisions []EnvCollision
+	for _, key := range keys {
+		if len(paths[key]) > 1 {
+			collisions = append(collisions, EnvCollision{Key: key, Paths: paths[key]})
+		}
+	}
+	return collisions
+}
@@ -0,0 +1,79 @@
+package konfig
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type collidingConfig struct {
+	FooBar   string
+	Foo_Bar  string
+	Database struct {
+		Port int `env:"DB_PORT"`
+	}
+	DatabaseDbPort int
+	Other          string `env:"FOOBAR"`
+}
+
+func TestCheckReportsEnvCollisions(t *testing.T) {
+	err := Check(&collidingConfig{}, WithEnvPrefix("APP"))
+
+	var collision *EnvCollisionError
+	if !errors.As(err, &collision) {
+		t.Fatalf("expected EnvCollisionError, got %v", err)
+	}
+	want := []EnvCollision{
+		{Key: "APP_FOO_BAR", Paths: []string{"FooBar", "Foo_Bar"}},
+		{Key: "APP_DATABASE_DB_PORT", Paths: []string{"Database.Port", "DatabaseDbPort"}},
+	}
+	if !reflect.DeepEqual(collision.Collisions, want) {
+		t.Fatalf("unexpected collisions %+v", collision.Collisions)
+	}
+	wantMsg := "konfig: colliding environment keys: APP_FOO_BAR is read by FooBar and Foo_Bar; APP_DATABASE_DB_PORT is read by Database.Port and DatabaseDbPort"
+	if err.Error() != wantMsg {
+		t.Fatalf("unexpected message:\n got %s\nwant %s", err.Error(), wantMsg)
+	}
+
+	t.Setenv("APP_FOO_BAR", "x")
+	var c collidingConfig
+	if err := Load(&c, WithEnvPrefix("APP")); !errors.As(err, &collision) {
+		t.Fatalf("expected Load to report collisions, got %v", err)
+	}
+}
+
+func TestCheckReportsIndexedOverlaps(t *testing.T) {
+	type cfg struct {
+		Ups      []envUpstream
+		Ups0Host string
+		Ups2     string
+		DBs      map[string]envDB
+		DBsPort  int `env:"DBS_MAIN_PORT"`
+	}
+
+	err := Check(&cfg{}, WithEnvPrefix("APP"))
+	var collision *EnvCollisionError
+	if !errors.As(err, &collision) {
+		t.Fatalf("expected EnvCollisionError, got %v", err)
+	}
+	want := []EnvCollision{
+		{Key: "APP_UPS_0_HOST", Paths: []string{"Ups", "Ups0Host"}},
+		{Key: "APP_DBS_MAIN_PORT", Paths: []string{"DBs", "DBsPort"}},
+	}
+	if !reflect.DeepEqual(collision.Collisions, want) {
+		t.Fatalf("unexpected collisions %+v", collision.Collisions)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	if err := Check(sourceConfig{}); err != nil {
+		t.Fatalf("expected value config without collisions to pass, got %v", err)
+	}
+	if err := Check(&collidingConfig{}, WithSources(FileSource{Path: "app.yaml"})); err != nil {
+		t.Fatalf("expected no env checks without an env source, got %v", err)
+	}
+	if err := Check(42); err == nil || !strings.Contains(err.Error(), "struct") {
+		t.Fatalf("expected non-struct error, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package konfig
+
+import "reflect"
+
+// deepCopy returns a copy of v that shares no pointers, maps or slices with
+// it, so loading into the copy can never write through to the original.
+// Unexported struct fields are copied shallowly. Pointers that appear more
+// than once keep their aliasing in the copy, which also makes cycles safe.
+func deepCopy(v reflect.Value) reflect.Value {
+	return copier{}.copy(v)
+}
+
+type copier map[uintptr]reflect.Value
+
+func (c copier) copy(v reflect.Value) reflect.Value {
+	out := reflect.New(v.Type()).Elem()
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return out
+		}
+		if copied, ok := c[v.Pointer()]; ok && copied.Type() == v.Type() {
+			return copied
+		}
+		out.Set(reflect.New(v.Type().Elem()))
+		c[v.Pointer()] = out
+		out.Elem().Set(c.copy(v.Elem()))
+	case reflect.Interface:
+		if !v.IsNil() {
+			out.Set(c.copy(v.Elem()))
+		}
+	case reflect.Struct:
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if out.Field(i).CanSet() {
+				out.Field(i).Set(c.copy(v.Field(i)))
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return out
+		}
+		out.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(c.copy(v.Index(i)))
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(c.copy(v.Index(i)))
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return out
+		}
+		out.Set(reflect.MakeMapWithSize(v.Type(), v.Len()))
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), c.copy(iter.Value()))
+		}
+	default:
+		out.Set(v)
+	}
+
+	return out
+}
@@ -0,0 +1,89 @@
+package konfig
+
+import (
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDeepCopy(t *testing.T) {
+	type node struct {
+		Name string
+		Next *node
+	}
+	type cfg struct {
+		Labels  map[string][]string
+		Ports   []int
+		Pair    [2]*int
+		Any     interface{}
+		Primary *node
+		Alias   *node
+		hidden  *int
+	}
+
+	port := 80
+	shared := &node{Name: "a"}
+	shared.Next = shared
+	orig := cfg{
+		Labels:  map[string][]string{"tier": {"web"}},
+		Ports:   []int{1, 2},
+		Pair:    [2]*int{&port, nil},
+		Any:     map[string]interface{}{"k": []interface{}{"v"}},
+		Primary: shared,
+		Alias:   shared,
+		hidden:  &port,
+	}
+
+	copied := deepCopy(reflect.ValueOf(orig)).Interface().(cfg)
+	if !reflect.DeepEqual(copied, orig) {
+		t.Fatalf("expected equal copy, got %+v", copied)
+	}
+
+	copied.Labels["tier"][0] = "changed"
+	copied.Ports[0] = 9
+	*copied.Pair[0] = 9
+	copied.Any.(map[string]interface{})["k"] = nil
+	copied.Primary.Name = "changed"
+
+	if orig.Labels["tier"][0] != "web" || orig.Ports[0] != 1 || port != 80 || orig.Any.(map[string]interface{})["k"] == nil || shared.Name != "a" {
+		t.Fatalf("expected original untouched, got %+v", orig)
+	}
+	if copied.Alias != copied.Primary || copied.Primary.Next != copied.Primary {
+		t.Fatalf("expected aliasing and cycles preserved in the copy")
+	}
+	if copied.hidden != orig.hidden {
+		t.Fatalf("expected unexported fields copied shallowly")
+	}
+}
+
+func TestLoadFailureLeavesUnexportedStateUntouched(t *testing.T) {
+	type cfg struct {
+		N    *big.Int
+		M    big.Int
+		Port int `validate:"min=1"`
+	}
+
+	file := filepath.Join(t.TempDir(), "config.json")
+	mustWrite(t, file, `{"M":98765432109876543210}`)
+	t.Setenv("APP_N", "123456789012345678901234567890")
+
+	preset, _ := new(big.Int).SetString("987654321098765432109876543210", 10)
+	c := cfg{N: preset}
+	c.M.SetString("111111111111111111111", 10)
+
+	if err := Load(&c, WithFiles(file), WithEnvPrefix("APP")); err == nil {
+		t.Fatalf("expected validation error")
+	}
+	if c.N != preset || c.N.String() != "987654321098765432109876543210" || c.M.String() != "111111111111111111111" {
+		t.Fatalf("expected failed Load to leave the big.Int values untouched, got N=%v M=%v", c.N, &c.M)
+	}
+
+	c.Port = 1
+	if err := Load(&c, WithFiles(file), WithEnvPrefix("APP")); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.N.String() != "123456789012345678901234567890" || c.M.String() != "98765432109876543210" || preset.String() != "987654321098765432109876543210" {
+		t.Fatalf("expected loaded values in fresh big.Ints, got N=%v M=%v preset=%v", c.N, &c.M, preset)
+	}
+}
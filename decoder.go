@@ -0,0 +1,182 @@
+package konfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Decoder decodes the contents of a configuration file into v, which is a
+// *map[string]interface{}. Nested objects may be decoded as any map type and
+// numbers as any integer or float type; they are normalized before the
+// values are bound onto the config struct.
+type Decoder func(data []byte, v any) error
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[string]Decoder{}
+)
+
+// RegisterDecoder makes decode handle files with the extension ext (with or
+// without the leading dot, matched case-insensitively) in every Load. A new
+// extension is also tried by WithBase lookup, after the built-in ones.
+// Registering .json, .toml, .yaml or .yml replaces the built-in decoder for
+// that extension; keys are still matched with that format's tag rules.
+func RegisterDecoder(ext string, decode Decoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[normalizeExt(ext)] = decode
+}
+
+// WithDecoder is like RegisterDecoder but only applies to a single Load, and
+// takes precedence over registered decoders.
+func WithDecoder(ext string, decode Decoder) Option {
+	return func(o *options) {
+		if o.decoders == nil {
+			o.decoders = map[string]Decoder{}
+		}
+		o.decoders[normalizeExt(ext)] = decode
+	}
+}
+
+func normalizeExt(ext string) string {
+	return "." + strings.ToLower(strings.TrimPrefix(ext, "."))
+}
+
+// builtinFormats maps the extensions konfig decodes natively to their format.
+var builtinFormats = map[string]string{
+	".json": formatJSON,
+	".toml": formatTOML,
+	".yaml": formatYAML,
+	".yml":  formatYAML,
+}
+
+// lookupDecoder returns the decoder for ext, preferring the per-call ones.
+func lookupDecoder(local map[string]Decoder, ext string) (Decoder, bool) {
+	if decode, ok := local[ext]; ok {
+		return decode, true
+	}
+
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	decode, ok := decoders[ext]
+	return decode, ok
+}
+
+// extraExtensions lists, in sorted order, the extensions with a decoder that
+// are not already decoded natively.
+func extraExtensions(local map[string]Decoder) []string {
+	var exts []string
+	add := func(ext string) {
+		if _, builtin := builtinFormats[ext]; !builtin && !slices.Contains(exts, ext) {
+			exts = append(exts, ext)
+		}
+	}
+
+	for ext := range local {
+		add(ext)
+	}
+	decodersMu.RLock()
+	for ext := range decoders {
+		add(ext)
+	}
+	decodersMu.RUnlock()
+
+	slices.Sort(exts)
+	return exts
+}
+
+// decodeByExtension decodes data into a key tree, using a registered decoder
+// for the extension of file if there is one, the built-in decoder for the
+// extension otherwise, and content detection for unknown extensions. The
+// returned format selects the key rules used for binding; files decoded by a
+// decoder for a new extension follow the JSON rules.
+func decodeByExtension(file string, data []byte, local map[string]Decoder) (string, map[string]interface{}, error) {
+	ext := strings.ToLower(filepath.Ext(file))
+	format, builtin := builtinFormats[ext]
+
+	if decode, ok := lookupDecoder(local, ext); ok {
+		var tree map[string]interface{}
+		if err := decode(data, &tree); err != nil {
+			return "", nil, err
+		}
+		if !builtin {
+			format = strings.TrimPrefix(ext, ".")
+		}
+		normalized, err := normalizeValue(tree)
+		if err != nil {
+			return "", nil, err
+		}
+		tree, _ = normalized.(map[string]interface{})
+		return format, tree, nil
+	}
+
+	if builtin {
+		tree, err := decodeTree(format, data)
+		return format, tree, err
+	}
+	return tryFallbackDecoders(data)
+}
+
+// normalizeValue converts the values a third-party decoder produces into the
+// shapes the binder understands: map[string]interface{}, []interface{},
+// int64, float64, string, bool, time.Time and nil.
+func normalizeValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, string, bool, int64, float64, json.Number, time.Time:
+		return v, nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return normalizeValue(rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n := rv.Uint(); n > math.MaxInt64 {
+			return json.Number(strconv.FormatUint(n, 10)), nil
+		}
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Map:
+		out := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			item, err := normalizeValue(iter.Value().Interface())
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			out[key] = item
+		}
+		return out, nil
+	case reflect.Slice, reflect.Array:
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			item, err := normalizeValue(rv.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("%d: %w", i, err)
+			}
+			out[i] = item
+		}
+		return out, nil
+	}
+
+	return nil, fmt.Errorf("unsupported decoded value of type %T", value)
+}
@@ -0,0 +1,162 @@
+package konfig
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+// decodeProperties parses "section.key=value" lines, decoding sections as
+// map[interface{}]interface{} the way some YAML libraries do.
+func decodeProperties(data []byte, v any) error {
+	root := map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return errors.New("missing =")
+		}
+		section, name, nested := strings.Cut(key, ".")
+		if !nested {
+			root[key] = value
+			continue
+		}
+		child, ok := root[section].(map[interface{}]interface{})
+		if !ok {
+			child = map[interface{}]interface{}{}
+			root[section] = child
+		}
+		child[name] = value
+	}
+
+	*v.(*map[string]interface{}) = root
+	return nil
+}
+
+type decoderConfig struct {
+	Name     string `json:"name" toml:"title"`
+	Database struct {
+		Host string `json:"host"`
+	} `json:"database"`
+	Port int `json:"port"`
+}
+
+func TestWithDecoderHandlesNewExtension(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.properties")
+	mustWrite(t, file, "name=svc\ndatabase.host=db.local\n")
+
+	var c decoderConfig
+	if err := Load(&c, WithFiles(file), WithDecoder("properties", decodeProperties)); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Name != "svc" || c.Database.Host != "db.local" {
+		t.Fatalf("unexpected config %+v", c)
+	}
+
+	var viaBase decoderConfig
+	if err := Load(&viaBase, WithBase(filepath.Join(dir, "app")), WithDecoder(".PROPERTIES", decodeProperties)); err != nil {
+		t.Fatalf("Load with base returned error: %v", err)
+	}
+	if viaBase.Name != "svc" {
+		t.Fatalf("expected base lookup to find app.properties, got %+v", viaBase)
+	}
+
+	mustWrite(t, file, "broken")
+	err := Load(&c, WithFiles(file), WithDecoder("properties", decodeProperties))
+	if err == nil || !strings.Contains(err.Error(), "konfig: decode "+file+": missing =") {
+		t.Fatalf("expected decoder error, got %v", err)
+	}
+}
+
+func TestWithDecoderFloatNumbers(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.jsn")
+
+	type cfg struct {
+		Port  int
+		Count uint8
+		Ratio float32
+		Any   interface{}
+	}
+
+	mustWrite(t, file, `{"Port":8080,"Count":3,"Ratio":0.5,"Any":2}`)
+	var c cfg
+	if err := Load(&c, WithFiles(file), WithDecoder(".jsn", json.Unmarshal)); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Port != 8080 || c.Count != 3 || c.Ratio != 0.5 || c.Any != float64(2) {
+		t.Fatalf("unexpected config %+v", c)
+	}
+
+	mustWrite(t, file, `{"Port":1.5}`)
+	if err := Load(&c, WithFiles(file), WithDecoder(".jsn", json.Unmarshal)); err == nil || !strings.Contains(err.Error(), "Port: strconv.ParseInt") {
+		t.Fatalf("expected fractional number rejected, got %v", err)
+	}
+}
+
+func TestRegisterDecoderReplacesBuiltin(t *testing.T) {
+	t.Cleanup(func() {
+		decodersMu.Lock()
+		delete(decoders, ".toml")
+		decodersMu.Unlock()
+	})
+
+	var calls int
+	RegisterDecoder(".toml", func(data []byte, v any) error {
+		calls++
+		return toml.Unmarshal(data, v)
+	})
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+	mustWrite(t, file, "title = \"svc\"\nport = 8080\n")
+
+	var c decoderConfig
+	if err := Load(&c, WithFiles(file)); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected registered decoder to be used, got %d calls", calls)
+	}
+	if c.Name != "svc" || c.Port != 8080 {
+		t.Fatalf("expected toml key rules kept, got %+v", c)
+	}
+
+	if got := baseFiles("app", extraExtensions(nil)); len(got) != 4 {
+		t.Fatalf("expected replaced builtin not to add base candidates, got %v", got)
+	}
+}
+
+func TestNormalizeValue(t *testing.T) {
+	got, err := normalizeValue(map[interface{}]interface{}{
+		1:       uint8(2),
+		"big":   uint64(math.MaxUint64),
+		"ratio": float32(0.5),
+		"list":  []int{1},
+		"ptr":   (*string)(nil),
+	})
+	if err != nil {
+		t.Fatalf("normalizeValue returned error: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"1":     int64(2),
+		"big":   json.Number("18446744073709551615"),
+		"ratio": float64(0.5),
+		"list":  []interface{}{int64(1)},
+		"ptr":   nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected normalized value %#v", got)
+	}
+
+	if _, err := normalizeValue(map[string]interface{}{"ch": make(chan int)}); err == nil || !strings.Contains(err.Error(), "ch: unsupported decoded value of type chan int") {
+		t.Fatalf("expected unsupported type error, got %v", err)
+	}
+}
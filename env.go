@@ -0,0 +1,282 @@
+package konfig
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// envUnsetSuffix turns the key of a field into the variable that clears it,
+// e.g. APP_DATABASE_PORT_UNSET.
+const envUnsetSuffix = "_UNSET"
+
+// envField is an environment key reachable from a struct type.
+type envField struct {
+	key  string
+	path string
+	// indexed marks slices and maps of structs, whose elements are addressed
+	// by further key segments such as APP_UPSTREAMS_0_HOST.
+	indexed bool
+	// collection is the slice or map type of an indexed field.
+	collection reflect.Type
+	// group marks a nested struct, whose key is only read with the unset
+	// suffix; its fields follow it in the list.
+	group bool
+}
+
+// envFields lists the environment keys setStructFieldsFromEnv consults for
+// structType, descending into nested structs the same way.
+func envFields(structType reflect.Type, prefix, path string) []envField {
+	var fields []envField
+
+	for i := 0; i < structType.NumField(); i++ {
+		fieldType := structType.Field(i)
+		if !fieldType.IsExported() {
+			continue
+		}
+
+		key, ok := envKey(fieldType, prefix)
+		if !ok {
+			continue
+		}
+		fieldPath := joinPath(path, fieldType.Name)
+		_, asJSON := tagOption(fieldType.Tag, "json")
+
+		nested := fieldType.Type
+		if nested.Kind() == reflect.Ptr {
+			nested = nested.Elem()
+		}
+		switch {
+		case asJSON:
+		case nested.Kind() == reflect.Struct && !isLeafStruct(nested):
+			fields = append(fields, envField{key: key, path: fieldPath, group: true})
+			fields = append(fields, envFields(nested, key, fieldPath)...)
+			continue
+		case structElem(fieldType.Type) != nil:
+			fields = append(fields, envField{key: key, path: fieldPath, indexed: true, collection: fieldType.Type})
+			continue
+		}
+
+		fields = append(fields, envField{key: key, path: fieldPath})
+	}
+
+	return fields
+}
+
+// structElem returns the element struct type of a slice or map whose elements
+// are structs (or pointers to structs) that env keys can address field by
+// field, or nil for any other type.
+func structElem(t reflect.Type) reflect.Type {
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Map {
+		return nil
+	}
+
+	elem := t.Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct || isLeafStruct(elem) {
+		return nil
+	}
+	return elem
+}
+
+// envUnset reports whether the variable key+"_UNSET" asks for the field read
+// from key to be cleared.
+func envUnset(key string) (bool, error) {
+	value, ok := os.LookupEnv(key + envUnsetSuffix)
+	if !ok {
+		return false, nil
+	}
+	unset, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("konfig: set %s%s: %w", key, envUnsetSuffix, err)
+	}
+	return unset, nil
+}
+
+// setCollectionFromEnv applies indexed keys to a slice or map of structs:
+// APP_UPSTREAMS_0_HOST targets element 0 and APP_DBS_REPORTING_PORT targets
+// the "reporting" entry. Elements already decoded from files are updated in
+// place; new ones are appended or created only when a variable sets them.
+func setCollectionFromEnv(field reflect.Value, key, path string, seen *origins) (int, error) {
+	if field.Kind() == reflect.Slice {
+		return setSliceFromEnv(field, key, path, seen)
+	}
+	return setMapFromEnv(field, key, path, seen)
+}
+
+func setSliceFromEnv(field reflect.Value, key, path string, seen *origins) (int, error) {
+	var applied int
+
+	elemFields := envFields(structElem(field.Type()), "", "")
+	for _, index := range envIndices(key, elemFields) {
+		if index > field.Len() {
+			return applied, fmt.Errorf("konfig: set %s_%d: index %d is beyond the next element (length %d)", key, index, index, field.Len())
+		}
+
+		elem := reflect.New(field.Type().Elem()).Elem()
+		if index < field.Len() {
+			elem = field.Index(index)
+		}
+
+		elemKey := key + "_" + strconv.Itoa(index)
+		count, err := setElementFromEnv(elem, elemKey, joinPath(path, strconv.Itoa(index)), seen)
+		if err != nil {
+			return applied, err
+		}
+		if count > 0 && index == field.Len() {
+			field.Set(reflect.Append(field, elem))
+		}
+		applied += count
+	}
+
+	return applied, nil
+}
+
+func setMapFromEnv(field reflect.Value, key, path string, seen *origins) (int, error) {
+	var applied int
+	mapType := field.Type()
+
+	for _, entry := range envMapEntries(field, key) {
+		mapKey := reflect.New(mapType.Key()).Elem()
+		if err := assignMapKey(mapKey, entry.name); err != nil {
+			return applied, fmt.Errorf("konfig: set %s_%s: key %q: %w", key, entry.segment, entry.name, err)
+		}
+
+		elem := reflect.New(mapType.Elem()).Elem()
+		if !field.IsNil() {
+			if existing := field.MapIndex(mapKey); existing.IsValid() {
+				elem.Set(existing)
+			}
+		}
+
+		count, err := setElementFromEnv(elem, key+"_"+entry.segment, joinPath(path, entry.name), seen)
+		if err != nil {
+			return applied, err
+		}
+		if count > 0 {
+			if field.IsNil() {
+				field.Set(reflect.MakeMap(mapType))
+			}
+			field.SetMapIndex(mapKey, elem)
+		}
+		applied += count
+	}
+
+	return applied, nil
+}
+
+// setElementFromEnv applies env keys to a struct or pointer-to-struct
+// element, allocating a nil pointer only when something beneath it is set.
+func setElementFromEnv(elem reflect.Value, key, path string, seen *origins) (int, error) {
+	if elem.Kind() != reflect.Ptr {
+		return setStructFieldsFromEnv(elem, key, path, seen)
+	}
+
+	target := elem
+	if elem.IsNil() {
+		target = reflect.New(elem.Type().Elem())
+	}
+	count, err := setStructFieldsFromEnv(target.Elem(), key, path, seen)
+	if count > 0 && elem.IsNil() {
+		elem.Set(target)
+	}
+	return count, err
+}
+
+// envIndices returns, in ascending order, the distinct numeric segments that
+// follow key in the environment, e.g. 0 and 1 for APP_UPSTREAMS_0_HOST and
+// APP_UPSTREAMS_1_PORT. A segment only counts when the rest of the name is
+// read by one of elemFields, so neither a misspelt element key nor a sibling
+// field such as APP_UPSTREAMS_2 addresses an element.
+func envIndices(key string, elemFields []envField) []int {
+	seen := map[int]bool{}
+	var indices []int
+
+	for _, name := range envNames(key + "_") {
+		segment, rest, _ := strings.Cut(name[len(key)+1:], "_")
+		index, err := strconv.Atoi(segment)
+		if err != nil || index < 0 || strconv.Itoa(index) != segment || seen[index] || !readsEnv(elemFields, rest) {
+			continue
+		}
+		seen[index] = true
+		indices = append(indices, index)
+	}
+
+	sort.Ints(indices)
+	return indices
+}
+
+// envMapEntry pairs the env key segment that addresses a map entry with the
+// map key it stands for.
+type envMapEntry struct {
+	segment string
+	name    string
+}
+
+// envMapEntries finds the map entries addressed beneath key. Existing map
+// keys are matched through toEnvKey; otherwise the segment before a known
+// element field key becomes a new, lower-cased map key, so
+// APP_DBS_READ_REPLICA_PORT creates "read_replica".
+func envMapEntries(field reflect.Value, key string) []envMapEntry {
+	elemFields := envFields(structElem(field.Type()), "", "")
+	matchesField := func(rest string) bool {
+		return readsEnv(elemFields, rest)
+	}
+
+	existing := map[string]string{}
+	if !field.IsNil() {
+		for _, mapKey := range field.MapKeys() {
+			name := fmt.Sprint(mapKey.Interface())
+			existing[toEnvKey(name)] = name
+		}
+	}
+
+	found := map[string]bool{}
+	var entries []envMapEntry
+	for _, name := range envNames(key + "_") {
+		rest := name[len(key)+1:]
+
+		segment := ""
+		for envName := range existing {
+			if len(envName) > len(segment) && strings.HasPrefix(rest, envName+"_") && matchesField(rest[len(envName)+1:]) {
+				segment = envName
+			}
+		}
+		for i := 1; segment == "" && i < len(rest); i++ {
+			if rest[i] == '_' && matchesField(rest[i+1:]) {
+				segment = rest[:i]
+			}
+		}
+		if segment == "" || found[segment] {
+			continue
+		}
+		found[segment] = true
+
+		mapKey, ok := existing[segment]
+		if !ok {
+			mapKey = strings.ToLower(segment)
+		}
+		entries = append(entries, envMapEntry{segment: segment, name: mapKey})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].segment < entries[j].segment })
+	return entries
+}
+
+// envNames lists the names of environment variables starting with prefix.
+func envNames(prefix string) []string {
+	var names []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,205 @@
+package konfig
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type envUpstream struct {
+	Host string
+	Port int
+}
+
+type envDB struct {
+	Host string
+	Port int
+	TLS  *struct {
+		Cert string
+	}
+}
+
+type envCollections struct {
+	Upstreams []envUpstream
+	Backups   []*envUpstream
+	DBs       map[string]envDB
+	Shards    map[int]*envDB
+}
+
+func TestEnvIndexedSlicesMergeWithFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	mustWrite(t, file, "Upstreams:\n  - Host: a\n    Port: 80\n  - Host: b\n    Port: 81\n")
+
+	t.Setenv("APP_UPSTREAMS_1_PORT", "8081")
+	t.Setenv("APP_UPSTREAMS_2_HOST", "c")
+	t.Setenv("APP_BACKUPS_0_HOST", "backup")
+
+	var c envCollections
+	if err := Load(&c, WithFiles(file), WithEnvPrefix("APP")); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if len(c.Upstreams) != 3 {
+		t.Fatalf("expected slice to grow to 3, got %+v", c.Upstreams)
+	}
+	if c.Upstreams[0] != (envUpstream{Host: "a", Port: 80}) {
+		t.Fatalf("expected first upstream untouched, got %+v", c.Upstreams[0])
+	}
+	if c.Upstreams[1] != (envUpstream{Host: "b", Port: 8081}) {
+		t.Fatalf("expected port override merged with file, got %+v", c.Upstreams[1])
+	}
+	if c.Upstreams[2] != (envUpstream{Host: "c"}) {
+		t.Fatalf("expected appended upstream, got %+v", c.Upstreams[2])
+	}
+	if len(c.Backups) != 1 || c.Backups[0] == nil || c.Backups[0].Host != "backup" {
+		t.Fatalf("expected pointer element allocated, got %+v", c.Backups)
+	}
+}
+
+func TestEnvIndexedMapsMergeWithFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.json")
+	mustWrite(t, file, `{"DBs":{"read_replica":{"Host":"replica","Port":5432}}}`)
+
+	t.Setenv("APP_DBS_READ_REPLICA_PORT", "6432")
+	t.Setenv("APP_DBS_REPORTING_HOST", "reports")
+	t.Setenv("APP_DBS_REPORTING_PORT", "5433")
+	t.Setenv("APP_DBS_REPORTING_TLS_CERT", "cert.pem")
+	t.Setenv("APP_SHARDS_3_HOST", "shard-3")
+
+	var c envCollections
+	if err := Load(&c, WithFiles(file), WithEnvPrefix("APP")); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	replica := c.DBs["read_replica"]
+	if replica.Host != "replica" || replica.Port != 6432 {
+		t.Fatalf("expected existing entry merged, got %+v", replica)
+	}
+	reporting, ok := c.DBs["reporting"]
+	if !ok || reporting.Host != "reports" || reporting.Port != 5433 {
+		t.Fatalf("expected new reporting entry, got %+v", c.DBs)
+	}
+	if reporting.TLS == nil || reporting.TLS.Cert != "cert.pem" {
+		t.Fatalf("expected nested pointer set on new entry, got %+v", reporting.TLS)
+	}
+	if shard := c.Shards[3]; shard == nil || shard.Host != "shard-3" {
+		t.Fatalf("expected int-keyed entry, got %+v", c.Shards)
+	}
+}
+
+func TestEnvIndexedSliceGap(t *testing.T) {
+	t.Setenv("APP_UPSTREAMS_2_HOST", "c")
+
+	var c envCollections
+	err := Load(&c, WithEnvPrefix("APP"))
+	if err == nil || !strings.Contains(err.Error(), "set APP_UPSTREAMS_2: index 2 is beyond the next element (length 0)") {
+		t.Fatalf("expected gap error, got %v", err)
+	}
+}
+
+func TestEnvIndexedIgnoresUnrelatedKeys(t *testing.T) {
+	t.Setenv("APP_UPSTREAMS_X_HOST", "ignored")
+	t.Setenv("APP_UPSTREAMS_01_HOST", "ignored")
+	t.Setenv("APP_DBS_REPORTING_UNKNOWN", "ignored")
+	t.Setenv("APP_UPSTREAMS_0_UNKNOWN", "ignored")
+
+	var c envCollections
+	if err := Load(&c, WithEnvPrefix("APP"), WithDefaultsAsSource()); err != ErrNoSources {
+		t.Fatalf("expected nothing applied, got %v (%+v)", err, c)
+	}
+	if c.Upstreams != nil || c.DBs != nil {
+		t.Fatalf("expected collections untouched, got %+v", c)
+	}
+}
+
+func TestEnvIndexedSkipsNamesNoElementReads(t *testing.T) {
+	type cfg struct {
+		Ups  []envUpstream
+		Ups2 string
+	}
+
+	t.Setenv("APP_UPS_5_HSOT", "typo")
+	t.Setenv("APP_UPS_2", "sibling")
+	t.Setenv("APP_UPS_0_HOST", "a")
+
+	var c cfg
+	var stray []StrayEnv
+	err := Load(&c, WithEnvPrefix("APP"), WithStrayEnvWarnings(func(v StrayEnv) { stray = append(stray, v) }))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Ups2 != "sibling" || len(c.Ups) != 1 || c.Ups[0].Host != "a" {
+		t.Fatalf("unexpected config %+v", c)
+	}
+	if len(stray) != 1 || stray[0].Key != "APP_UPS_5_HSOT" || stray[0].Suggestion != "APP_UPS_5_HOST" {
+		t.Fatalf("expected the typo reported as stray, got %+v", stray)
+	}
+}
+
+func TestEnvUnset(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.yaml")
+	mustWrite(t, file, "Name: svc\nPort: 8080\nDB:\n  Host: db\n  TLS:\n    Cert: a.pem\nUpstreams:\n  - Host: a\n    Port: 1\n")
+
+	type cfg struct {
+		Name      string
+		Port      int
+		DB        *envDB
+		Upstreams []envUpstream
+	}
+
+	t.Setenv("APP_NAME_UNSET", "true")
+	t.Setenv("APP_PORT_UNSET", "1")
+	t.Setenv("APP_PORT", "9090")
+	t.Setenv("APP_DB_TLS_UNSET", "true")
+	t.Setenv("APP_UPSTREAMS_0_PORT_UNSET", "true")
+
+	var c cfg
+	var report Provenance
+	if err := Load(&c, WithFiles(file), WithEnvPrefix("APP"), WithProvenance(&report), WithStrayEnvErrors()); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Name != "" || c.Port != 9090 || c.DB == nil || c.DB.Host != "db" || c.DB.TLS != nil || c.Upstreams[0] != (envUpstream{Host: "a"}) {
+		t.Fatalf("unexpected config %+v", c)
+	}
+
+	if field, _ := report.Lookup("Name"); field.Origin != (Origin{Kind: OriginEnv, Name: "APP_NAME_UNSET", Unset: true}) {
+		t.Fatalf("expected Name cleared by env, got %+v", field)
+	}
+	if field, _ := report.Lookup("Port"); field.Origin.Name != "APP_PORT" || !field.Overridden[0].Unset {
+		t.Fatalf("expected Port set after being cleared, got %+v", field)
+	}
+	if field, _ := report.Lookup("DB.TLS.Cert"); field.Origin.String() != "env APP_DB_TLS_UNSET (unset)" {
+		t.Fatalf("expected nested field cleared with its parent, got %+v", field)
+	}
+
+	t.Setenv("APP_DB_UNSET", "maybe")
+	if err := Load(&c, WithFiles(file), WithEnvPrefix("APP")); err == nil || !strings.Contains(err.Error(), "APP_DB_UNSET") {
+		t.Fatalf("expected invalid unset value error, got %v", err)
+	}
+}
+
+func TestEnvFields(t *testing.T) {
+	type cfg struct {
+		Name     string
+		Database struct {
+			Port int `env:"DB_PORT"`
+		}
+		Upstreams []envUpstream
+		Raw       []envUpstream `env:",json"`
+		Skipped   string        `env:"-"`
+	}
+
+	var got []string
+	for _, field := range envFields(reflect.TypeOf(cfg{}), "APP", "") {
+		got = append(got, field.key+"="+field.path)
+	}
+
+	want := "APP_NAME=Name APP_DATABASE=Database APP_DATABASE_DB_PORT=Database.Port APP_UPSTREAMS=Upstreams APP_RAW=Raw"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("unexpected env fields:\n got %s\nwant %s", strings.Join(got, " "), want)
+	}
+}
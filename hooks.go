@@ -0,0 +1,184 @@
+package konfig
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Defaulter is implemented by configuration structs that compute their own
+// defaults. Load calls SetDefaults on the root config and on every nested
+// struct, including the elements of slices and maps, before `default` tags
+// are applied and any source is read.
+type Defaulter interface {
+	SetDefaults()
+}
+
+// AfterLoader is implemented by configuration structs that need to derive or
+// normalize values once every source has been applied. Load calls AfterLoad on
+// nested structs before their parents, ahead of validation.
+type AfterLoader interface {
+	AfterLoad() error
+}
+
+// Validator is implemented by configuration structs that check their own
+// consistency. Load calls Validate on nested structs before their parents and
+// reports every failure, each wrapped with the path of the struct that
+// returned it.
+type Validator interface {
+	Validate() error
+}
+
+func runDefaulters(structValue reflect.Value) {
+	_ = walkStructs(structValue, "", true, func(v reflect.Value, _ string) error {
+		if hook, ok := hookTarget(v).(Defaulter); ok {
+			hook.SetDefaults()
+		}
+		return nil
+	})
+}
+
+func runAfterLoaders(structValue reflect.Value) error {
+	return walkStructs(structValue, "", false, func(v reflect.Value, path string) error {
+		hook, ok := hookTarget(v).(AfterLoader)
+		if !ok {
+			return nil
+		}
+		if err := hook.AfterLoad(); err != nil {
+			return fmt.Errorf("konfig: after load %s: %w", describePath(path), err)
+		}
+		return nil
+	})
+}
+
+func runValidators(structValue reflect.Value) error {
+	var errs []error
+	_ = walkStructs(structValue, "", false, func(v reflect.Value, path string) error {
+		hook, ok := hookTarget(v).(Validator)
+		if !ok {
+			return nil
+		}
+		if err := hook.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("konfig: validate %s: %w", describePath(path), err))
+		}
+		return nil
+	})
+	return errors.Join(errs...)
+}
+
+// walkStructs calls visit for structValue and every struct reachable through
+// exported struct fields, non-nil pointers to structs and the elements of
+// slices, arrays and maps, which are visited under their index or key as in
+// Ups.0. Embedded structs are descended into but not visited themselves, since
+// their methods are already promoted to the enclosing struct.
+func walkStructs(structValue reflect.Value, path string, parentsFirst bool, visit func(reflect.Value, string) error) error {
+	return walkStructsFrom(structValue, path, parentsFirst, true, visit)
+}
+
+func walkStructsFrom(structValue reflect.Value, path string, parentsFirst, self bool, visit func(reflect.Value, string) error) error {
+	if self && parentsFirst {
+		if err := visit(structValue, path); err != nil {
+			return err
+		}
+	}
+
+	structType := structValue.Type()
+	for i := 0; i < structValue.NumField(); i++ {
+		fieldType := structType.Field(i)
+		if !fieldType.IsExported() {
+			continue
+		}
+
+		err := walkValue(structValue.Field(i), joinPath(path, fieldType.Name), parentsFirst, !fieldType.Anonymous, visit)
+		if err != nil {
+			return err
+		}
+	}
+
+	if self && !parentsFirst {
+		return visit(structValue, path)
+	}
+	return nil
+}
+
+// walkValue walks v when it is a struct or a non-nil pointer to one, and the
+// elements of v when it is a collection.
+func walkValue(v reflect.Value, path string, parentsFirst, self bool, visit func(reflect.Value, string) error) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		return walkStructsFrom(v, path, parentsFirst, self, visit)
+	}
+	return forEachElement(v, path, func(elem reflect.Value, elemPath string) error {
+		return walkValue(elem, elemPath, parentsFirst, true, visit)
+	})
+}
+
+// forEachElement calls fn with every element of a slice, array or map that
+// may hold structs, and its path. Map entries are passed as addressable
+// copies and stored back afterwards, so fn may modify them; keys are visited
+// in sorted order.
+func forEachElement(v reflect.Value, path string, fn func(reflect.Value, string) error) error {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if !holdsStructs(v.Type().Elem()) {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := fn(v.Index(i), joinPath(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if !holdsStructs(v.Type().Elem()) {
+			return nil
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			if err := fn(elem, joinPath(path, fmt.Sprint(key.Interface()))); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+	}
+	return nil
+}
+
+// holdsStructs reports whether values of t can contain structs to walk.
+func holdsStructs(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// hookTarget returns the value to type-assert hook interfaces against,
+// preferring a pointer so pointer-receiver methods are found.
+func hookTarget(v reflect.Value) interface{} {
+	if v.CanAddr() {
+		return v.Addr().Interface()
+	}
+	return v.Interface()
+}
+
+func describePath(path string) string {
+	if path == "" {
+		return "config"
+	}
+	return path
+}
@@ -0,0 +1,197 @@
+package konfig
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type hookedDB struct {
+	Host string
+	Port int
+}
+
+func (d *hookedDB) SetDefaults() {
+	if d.Host == "" {
+		d.Host = "localhost"
+	}
+}
+
+func (d hookedDB) Validate() error {
+	if d.Port == 0 {
+		return errors.New("port must be set")
+	}
+	return nil
+}
+
+type hookedCache struct {
+	Size int
+}
+
+func (c *hookedCache) Validate() error {
+	if c.Size < 0 {
+		return errors.New("size must not be negative")
+	}
+	return nil
+}
+
+type hookedConfig struct {
+	Name     string
+	Port     int `default:"8080"`
+	Database hookedDB
+	Cache    *hookedCache
+	Replica  *hookedDB
+
+	order []string
+}
+
+func (c *hookedConfig) SetDefaults() {
+	c.Port = 9000
+	c.order = append(c.order, "defaults")
+}
+
+func (c *hookedConfig) AfterLoad() error {
+	c.Name = strings.ToUpper(c.Name)
+	c.order = append(c.order, "after")
+	return nil
+}
+
+func (c *hookedConfig) Validate() error {
+	c.order = append(c.order, "validate")
+	if c.Name == "" {
+		return errors.New("name must be set")
+	}
+	return nil
+}
+
+func TestLoadRunsHooks(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.json")
+	mustWrite(t, file, `{"Name":"svc","Database":{"Port":5432}}`)
+
+	var c hookedConfig
+	if err := Load(&c, WithFiles(file)); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if c.Port != 9000 {
+		t.Fatalf("expected Defaulter to run before default tags, got %d", c.Port)
+	}
+	if c.Database.Host != "localhost" {
+		t.Fatalf("expected nested Defaulter to run, got %q", c.Database.Host)
+	}
+	if c.Name != "SVC" {
+		t.Fatalf("expected AfterLoad to normalize name, got %q", c.Name)
+	}
+	if got := strings.Join(c.order, ","); got != "defaults,after,validate" {
+		t.Fatalf("unexpected hook order %q", got)
+	}
+}
+
+func TestLoadValidatorErrorsWrappedWithPath(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.json")
+	mustWrite(t, file, `{"Cache":{"Size":-1},"Replica":{"Host":"replica"}}`)
+
+	var c hookedConfig
+	err := Load(&c, WithFiles(file))
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+
+	for _, want := range []string{
+		"validate Database: port must be set",
+		"validate Cache: size must not be negative",
+		"validate Replica: port must be set",
+		"validate config: name must be set",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in error, got %v", want, err)
+		}
+	}
+}
+
+type trimmedHost struct {
+	Host string
+}
+
+func (h *trimmedHost) AfterLoad() error {
+	h.Host = strings.TrimSpace(h.Host)
+	return nil
+}
+
+func TestLoadHooksRunOnCollectionElements(t *testing.T) {
+	type cfg struct {
+		Replicas []hookedDB
+		Caches   map[string]*hookedCache
+		Hosts    map[string]trimmedHost
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.json")
+	mustWrite(t, file, `{"Replicas":[{"Port":1},{"Host":"b"}],"Caches":{"a":{"Size":-1}},"Hosts":{"x":{"Host":" x "}}}`)
+
+	var c cfg
+	err := Load(&c, WithFiles(file))
+	for _, want := range []string{"validate Replicas.1: port must be set", "validate Caches.a: size must not be negative"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in error, got %v", want, err)
+		}
+	}
+
+	mustWrite(t, file, `{"Hosts":{"x":{"Host":" x "}}}`)
+	if err := Load(&c, WithFiles(file)); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Hosts["x"].Host != "x" {
+		t.Fatalf("expected AfterLoad to update the map entry, got %+v", c.Hosts)
+	}
+}
+
+type failingAfterLoad struct {
+	Name string
+}
+
+func (f *failingAfterLoad) AfterLoad() error {
+	return errors.New("cannot derive")
+}
+
+func TestLoadAfterLoadError(t *testing.T) {
+	t.Setenv("APP_NESTED_NAME", "value")
+
+	var c struct {
+		Nested failingAfterLoad
+	}
+
+	err := Load(&c, WithEnvPrefix("APP"))
+	if err == nil || !strings.Contains(err.Error(), "after load Nested: cannot derive") {
+		t.Fatalf("expected after load error, got %v", err)
+	}
+}
+
+type embeddedValidator struct {
+	calls *int
+}
+
+func (e embeddedValidator) Validate() error {
+	*e.calls++
+	return nil
+}
+
+func TestLoadEmbeddedValidatorRunsOnce(t *testing.T) {
+	t.Setenv("APP_NAME", "svc")
+
+	calls := 0
+	c := struct {
+		embeddedValidator
+		Name string
+	}{embeddedValidator: embeddedValidator{calls: &calls}}
+
+	if err := Load(&c, WithEnvPrefix("APP")); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected promoted Validate to run once, ran %d times", calls)
+	}
+}
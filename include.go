@@ -0,0 +1,204 @@
+package konfig
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// includeKey is the top-level key whose value names the files a
+// configuration file includes. TOML spells it as a quoted key.
+const includeKey = "$include"
+
+// WithIncludeRoot confines the files $include directives may read to dir.
+// By default an include may only read files in the directory of the
+// top-level file that started the chain, or below it.
+func WithIncludeRoot(dir string) Option {
+	return func(o *options) {
+		o.includeRoot = dir
+	}
+}
+
+// withIncludeHook calls fn with the name of every included file read from the
+// operating system, so Watch can poll them too.
+func withIncludeHook(fn func(name string)) Option {
+	return func(o *options) {
+		o.onInclude = fn
+	}
+}
+
+// takeIncludes removes the include directive from tree and returns its
+// patterns, which may be given as a string or a list of strings.
+func takeIncludes(tree map[string]interface{}) ([]string, error) {
+	value, ok := tree[includeKey]
+	if !ok {
+		return nil, nil
+	}
+	delete(tree, includeKey)
+
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		patterns := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: expected a string or list of strings", includeKey)
+			}
+			patterns[i] = s
+		}
+		return patterns, nil
+	}
+	return nil, fmt.Errorf("%s: expected a string or list of strings", includeKey)
+}
+
+// includer reads the files included by one top-level file. Every name it
+// handles is a slash-separated path relative to root.
+type includer struct {
+	fsys fs.FS
+	root string
+	// native marks an fsys that reads the operating system.
+	native bool
+	closer io.Closer
+	// chain lists the files being decoded, outermost first.
+	chain []string
+}
+
+// openIncluder prepares to resolve the includes of the top-level file name,
+// which is read from fsys or, when fsys is nil, the operating system.
+func (l *Layer) openIncluder(fsys fs.FS, name string) (*includer, error) {
+	if fsys == nil {
+		root := l.includeRoot
+		if root == "" {
+			root = filepath.Dir(name)
+		}
+		rel, err := relativeTo(root, name)
+		if err != nil {
+			return nil, err
+		}
+		r, err := os.OpenRoot(root)
+		if err != nil {
+			return nil, fmt.Errorf("konfig: include root %s: %w", root, err)
+		}
+		return &includer{fsys: r.FS(), root: root, native: true, closer: r, chain: []string{rel}}, nil
+	}
+
+	root := path.Clean(l.includeRoot)
+	if l.includeRoot == "" {
+		root = path.Dir(name)
+	}
+	rel := path.Clean(name)
+	if root != "." {
+		var ok bool
+		if rel, ok = strings.CutPrefix(rel, root+"/"); !ok {
+			return nil, fmt.Errorf("konfig: %s is outside the include root %s", name, root)
+		}
+	}
+	sub, err := fs.Sub(fsys, root)
+	if err != nil {
+		return nil, fmt.Errorf("konfig: include root %s: %w", root, err)
+	}
+	return &includer{fsys: sub, root: root, chain: []string{rel}}, nil
+}
+
+// relativeTo returns name as a slash-separated path relative to root, which
+// it must lie within.
+func relativeTo(root, name string) (string, error) {
+	absRoot, err := filepath.Abs(root)
+	if err == nil {
+		var absName string
+		if absName, err = filepath.Abs(name); err == nil {
+			var rel string
+			if rel, err = filepath.Rel(absRoot, absName); err == nil && !escapes(filepath.ToSlash(rel)) {
+				return filepath.ToSlash(rel), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("konfig: %s is outside the include root %s", name, root)
+}
+
+func escapes(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, "../")
+}
+
+func (inc *includer) close() {
+	if inc.closer != nil {
+		inc.closer.Close()
+	}
+}
+
+// displayName turns a path relative to the root back into the name errors
+// and provenance report.
+func (inc *includer) displayName(rel string) string {
+	if inc.native {
+		return filepath.Join(inc.root, filepath.FromSlash(rel))
+	}
+	return path.Join(inc.root, rel)
+}
+
+// include decodes the files matched by patterns, relative to the file at the
+// end of the chain, in the order given. Glob matches are sorted.
+func (inc *includer) include(l *Layer, patterns []string) error {
+	from := inc.chain[len(inc.chain)-1]
+	fromName := inc.displayName(from)
+
+	for _, pattern := range patterns {
+		if pattern == "" || path.IsAbs(pattern) || filepath.IsAbs(pattern) {
+			return fmt.Errorf("konfig: include %q from %s: must be a relative path", pattern, fromName)
+		}
+		target := path.Join(path.Dir(from), filepath.ToSlash(pattern))
+		if escapes(target) {
+			return fmt.Errorf("konfig: include %q from %s: escapes the include root %s", pattern, fromName, inc.root)
+		}
+
+		matches := []string{target}
+		if strings.ContainsAny(target, "*?[") {
+			var err error
+			if matches, err = fs.Glob(inc.fsys, target); err != nil {
+				return fmt.Errorf("konfig: include %q from %s: %w", pattern, fromName, err)
+			}
+			sort.Strings(matches)
+		}
+
+		for _, rel := range matches {
+			if err := inc.decode(l, rel, pattern, fromName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decode reads and decodes one included file, rejecting cycles.
+func (inc *includer) decode(l *Layer, rel, pattern, fromName string) error {
+	for i, open := range inc.chain {
+		if open != rel {
+			continue
+		}
+		names := make([]string, 0, len(inc.chain)-i+1)
+		for _, name := range inc.chain[i:] {
+			names = append(names, inc.displayName(name))
+		}
+		names = append(names, inc.displayName(rel))
+		return fmt.Errorf("konfig: include cycle %s", strings.Join(names, " -> "))
+	}
+
+	name := inc.displayName(rel)
+	data, err := fs.ReadFile(inc.fsys, rel)
+	if err != nil {
+		return fmt.Errorf("konfig: include %q from %s: %w", pattern, fromName, err)
+	}
+	if inc.native && l.onInclude != nil {
+		l.onInclude(name)
+	}
+
+	inc.chain = append(inc.chain, rel)
+	defer func() { inc.chain = inc.chain[:len(inc.chain)-1] }()
+	return l.decode(inc.fsys, inc, name, data)
+}
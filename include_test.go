@@ -0,0 +1,147 @@
+package konfig
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type includeConfig struct {
+	Name     string   `json:"name" toml:"name"`
+	Region   string   `json:"region" toml:"region"`
+	Plugins  []string `json:"plugins" toml:"plugins" merge:"append"`
+	Database struct {
+		Host string `json:"host" toml:"host"`
+		Port int    `json:"port" toml:"port"`
+	} `json:"database" toml:"database"`
+}
+
+func TestLoadIncludes(t *testing.T) {
+	dir := t.TempDir()
+	app := filepath.Join(dir, "app.yaml")
+	mustWrite(t, app, "$include:\n  - shared/*.yaml\n  - db.toml\nname: app\n")
+	mustWrite(t, filepath.Join(dir, "shared", "b.yaml"), "plugins: [metrics]\nregion: us\n")
+	mustWrite(t, filepath.Join(dir, "shared", "a.yaml"), "plugins: [auth]\nregion: eu\nname: shared\n")
+	mustWrite(t, filepath.Join(dir, "db.toml"), "\"$include\" = \"nested/port.json\"\n\n[database]\nhost = \"db\"\nport = 1\n")
+	mustWrite(t, filepath.Join(dir, "nested", "port.json"), `{"database":{"port":5432}}`)
+
+	var c includeConfig
+	var report Provenance
+	if err := Load(&c, WithFiles(app), WithProvenance(&report), WithStrict()); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if c.Name != "app" || c.Region != "us" || !reflect.DeepEqual(c.Plugins, []string{"auth", "metrics"}) {
+		t.Fatalf("expected includes applied in order beneath the including file, got %+v", c)
+	}
+	if c.Database.Host != "db" || c.Database.Port != 1 {
+		t.Fatalf("expected nested include beneath db.toml, got %+v", c.Database)
+	}
+
+	if field, _ := report.Lookup("Region"); field.Origin.Name != filepath.Join(dir, "shared", "b.yaml") || len(field.Overridden) != 1 {
+		t.Fatalf("expected included files in provenance, got %+v", field)
+	}
+	if field, _ := report.Lookup("Database.Port"); field.Overridden[0].Name != filepath.Join(dir, "nested", "port.json") {
+		t.Fatalf("expected nested include in provenance, got %+v", field)
+	}
+}
+
+func TestLoadIncludeErrors(t *testing.T) {
+	dir := t.TempDir()
+	configDir := filepath.Join(dir, "config")
+	app := filepath.Join(configDir, "app.yaml")
+	mustWrite(t, filepath.Join(dir, "secret.yaml"), "name: secret\n")
+	mustWrite(t, filepath.Join(configDir, "a.yaml"), "$include: b.yaml\n")
+	mustWrite(t, filepath.Join(configDir, "b.yaml"), "$include: a.yaml\n")
+	if err := os.Symlink(filepath.Join(dir, "secret.yaml"), filepath.Join(configDir, "link.yaml")); err != nil {
+		t.Fatalf("symlink failed: %v", err)
+	}
+
+	cases := map[string]string{
+		"$include: ../secret.yaml\n":  `konfig: include "../secret.yaml" from ` + app + ": escapes the include root " + configDir,
+		"$include: link.yaml\n":       `konfig: include "link.yaml" from ` + app + ": openat link.yaml: path escapes from parent",
+		"$include: /etc/passwd\n":     `konfig: include "/etc/passwd" from ` + app + ": must be a relative path",
+		"$include: missing.yaml\n":    `konfig: include "missing.yaml" from ` + app + ": ",
+		"$include: {file: a.yaml}\n":  "konfig: decode " + app + ": $include: expected a string or list of strings",
+		"$include: [a.yaml]\nname: x": "konfig: include cycle " + filepath.Join(configDir, "a.yaml") + " -> " + filepath.Join(configDir, "b.yaml") + " -> " + filepath.Join(configDir, "a.yaml"),
+	}
+
+	for content, want := range cases {
+		mustWrite(t, app, content)
+
+		var c includeConfig
+		err := Load(&c, WithFiles(app))
+		if err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Fatalf("unexpected error for %q:\n got %v\nwant %s...", content, err, want)
+		}
+		if c.Name != "" {
+			t.Fatalf("expected nothing loaded, got %+v", c)
+		}
+	}
+
+	mustWrite(t, filepath.Join(configDir, "prod", "app.yaml"), "$include: ../a.json\n")
+	mustWrite(t, filepath.Join(configDir, "a.json"), `{"name":"shared"}`)
+	var c includeConfig
+	if err := Load(&c, WithFiles(filepath.Join(configDir, "prod", "app.yaml")), WithIncludeRoot(configDir)); err != nil || c.Name != "shared" {
+		t.Fatalf("expected WithIncludeRoot to allow the parent directory, got %+v (%v)", c, err)
+	}
+}
+
+func TestLoadIncludesFromFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"defaults/app.json":       {Data: []byte(`{"$include":["shared/*.json"],"name":"app"}`)},
+		"defaults/shared/db.json": {Data: []byte(`{"database":{"host":"db"}}`)},
+		"secret.json":             {Data: []byte(`{"name":"secret"}`)},
+	}
+
+	var c includeConfig
+	var report Provenance
+	if err := Load(&c, WithSources(FileSource{Path: "defaults/app.json", FS: fsys}), WithProvenance(&report)); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Name != "app" || c.Database.Host != "db" {
+		t.Fatalf("unexpected config %+v", c)
+	}
+	if field, _ := report.Lookup("Database.Host"); field.Origin.Name != "defaults/shared/db.json" {
+		t.Fatalf("expected included file in provenance, got %+v", field)
+	}
+
+	fsys["defaults/app.json"] = &fstest.MapFile{Data: []byte(`{"$include":"../secret.json"}`)}
+	if err := Load(&c, WithSources(FileSource{Path: "defaults/app.json", FS: fsys})); err == nil || !strings.Contains(err.Error(), "escapes the include root defaults") {
+		t.Fatalf("expected escaping include rejected, got %v", err)
+	}
+}
+
+func TestWatchReloadsOnIncludedFileChange(t *testing.T) {
+	dir := t.TempDir()
+	app := filepath.Join(dir, "app.yaml")
+	shared := filepath.Join(dir, "shared.yaml")
+	mustWrite(t, app, "$include: shared.yaml\n")
+	mustWrite(t, shared, "name: a\n")
+
+	store, err := Watch[includeConfig](context.Background(), WithFiles(app), WithPollInterval(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Watch returned error: %v", err)
+	}
+	defer store.Close()
+
+	changes := make(chan includeConfig, 1)
+	store.OnChange(func(_, new includeConfig) {
+		changes <- new
+	})
+
+	mustWrite(t, shared, "name: bb\n")
+	select {
+	case got := <-changes:
+		if got.Name != "bb" {
+			t.Fatalf("unexpected reloaded config %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for reload of included file")
+	}
+}
@@ -0,0 +1,240 @@
+package konfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// WithInterpolation expands references to environment variables in the
+// string values of configuration files:
+//
+//	${VAR}            the value of VAR, or empty if it is unset
+//	${VAR:-default}   default when VAR is unset or empty
+//	${VAR:?message}   an error carrying message when VAR is unset or empty
+//
+// A name containing a dot, such as ${database.host}, refers to another key
+// of the merged configuration instead, with a leading dot for top-level keys
+// (${.name}). Such values are expanded once every source has been applied,
+// so an environment override of database.host flows into them, and a
+// reference cycle is an error.
+//
+// Expansion runs on decoded values, so substituted text is never parsed as
+// part of the file, and keys are left alone. Write $$ for a literal $.
+func WithInterpolation() Option {
+	return func(o *options) {
+		o.interpolate = true
+	}
+}
+
+// InterpolationError reports a reference in a file value that could not be
+// expanded.
+type InterpolationError struct {
+	// File is the file the value was read from.
+	File string
+	// Key is the dotted key path of the value as written in the file.
+	Key string
+	// Line is the 1-based line of the key, or 0 when unknown.
+	Line int
+	// Err describes the failed reference.
+	Err error
+}
+
+func (e *InterpolationError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location += ":" + strconv.Itoa(e.Line)
+	}
+	return fmt.Sprintf("konfig: interpolate %s: %s: %v", location, e.Key, e.Err)
+}
+
+func (e *InterpolationError) Unwrap() error {
+	return e.Err
+}
+
+// errDeferred stops the expansion of a value that refers to other keys.
+var errDeferred = errors.New("deferred reference")
+
+// resolver returns the value a reference name stands for, or "" when unset.
+type resolver func(name string) (string, error)
+
+// envResolver resolves environment variables and defers key references.
+func envResolver(name string) (string, error) {
+	if isKeyReference(name) {
+		return "", errDeferred
+	}
+	return os.Getenv(name), nil
+}
+
+// interpolateTree expands the environment references in every string value
+// of tree in place. Values referring to other keys are left as written and
+// their dotted key paths added to deferred. On failure it returns the key
+// path of the value along with the error.
+func interpolateTree(tree map[string]interface{}, path string, deferred map[string]bool) (string, error) {
+	for _, key := range sortedKeys(tree) {
+		value, keyPath, err := interpolateValue(tree[key], joinPath(path, key), deferred)
+		if err != nil {
+			return keyPath, err
+		}
+		tree[key] = value
+	}
+	return "", nil
+}
+
+func interpolateValue(value interface{}, path string, deferred map[string]bool) (interface{}, string, error) {
+	switch v := value.(type) {
+	case string:
+		expanded, err := expandString(v, envResolver)
+		if errors.Is(err, errDeferred) {
+			deferred[path] = true
+			return v, "", nil
+		}
+		return expanded, path, err
+	case map[string]interface{}:
+		keyPath, err := interpolateTree(v, path, deferred)
+		return v, keyPath, err
+	case []interface{}:
+		for i, item := range v {
+			expanded, keyPath, err := interpolateValue(item, joinPath(path, strconv.Itoa(i)), deferred)
+			if err != nil {
+				return nil, keyPath, err
+			}
+			v[i] = expanded
+		}
+		return v, "", nil
+	case []map[string]interface{}:
+		for i, item := range v {
+			if keyPath, err := interpolateTree(item, joinPath(path, strconv.Itoa(i)), deferred); err != nil {
+				return nil, keyPath, err
+			}
+		}
+		return v, "", nil
+	}
+	return value, "", nil
+}
+
+// expandString replaces the ${...} references in s with what resolve returns
+// for them. Defaults may themselves contain references.
+func expandString(s string, resolve resolver) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := closingBrace(s, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated reference in %q", s)
+			}
+			value, err := expandReference(s[i+2:end], resolve)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i = end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+// closingBrace returns the index of the } that closes a reference whose body
+// starts at start, skipping nested references.
+func closingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// expandReference resolves the body of a single ${...} reference.
+func expandReference(body string, resolve resolver) (string, error) {
+	name, operand, op := body, "", ""
+	if i := strings.Index(body, ":"); i >= 0 {
+		name, op, operand = body[:i], body[i:min(i+2, len(body))], body[min(i+2, len(body)):]
+	}
+	if !validEnvName(name) && !isKeyReference(name) {
+		return "", fmt.Errorf("invalid reference ${%s}", body)
+	}
+
+	value, err := resolve(name)
+	if err != nil {
+		return "", err
+	}
+	switch op {
+	case "":
+		return value, nil
+	case ":-":
+		if value != "" {
+			return value, nil
+		}
+		return expandString(operand, resolve)
+	case ":?":
+		if value != "" {
+			return value, nil
+		}
+		if operand == "" {
+			return "", fmt.Errorf("%s is not set", name)
+		}
+		message, err := expandString(operand, resolve)
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("%s: %s", name, message)
+	}
+	return "", fmt.Errorf("invalid reference ${%s}", body)
+}
+
+// isKeyReference reports whether name refers to a configuration key, such as
+// database.host or .name, rather than an environment variable.
+func isKeyReference(name string) bool {
+	if !strings.Contains(name, ".") || strings.ContainsAny(name, " \t${}") {
+		return false
+	}
+	parts := strings.Split(strings.TrimPrefix(name, "."), ".")
+	for _, part := range parts {
+		if part == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// validEnvName reports whether name is a shell-style variable name.
+func validEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
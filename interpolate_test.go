@@ -0,0 +1,106 @@
+package konfig
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWithInterpolation(t *testing.T) {
+	type cfg struct {
+		DSN      string            `json:"dsn" toml:"dsn"`
+		Region   string            `json:"region" toml:"region"`
+		Price    string            `json:"price" toml:"price"`
+		Hosts    []string          `json:"hosts" toml:"hosts"`
+		Labels   map[string]string `json:"labels" toml:"labels"`
+		Replicas []struct {
+			Host string `json:"host" toml:"host"`
+		} `json:"replicas" toml:"replicas"`
+	}
+
+	t.Setenv("DB_USER", "app")
+	t.Setenv("DB_PASS", `p"a:ss`)
+	t.Setenv("HOST_A", "a.internal")
+	t.Setenv("EMPTY", "")
+	t.Setenv("HOST_B", "")
+	t.Setenv("DB_NAME", "")
+
+	dir := t.TempDir()
+	cases := map[string]string{
+		"app.yaml": "dsn: postgres://${DB_USER}:${DB_PASS}@db/${DB_NAME:-app_${DB_USER}}\nregion: ${EMPTY:-eu}\nprice: $$5\nhosts: [\"${HOST_A}\", \"${HOST_B}\"]\nlabels:\n  ${KEY}: x\nreplicas:\n  - host: ${HOST_A}\n",
+		"app.toml": "dsn = \"postgres://${DB_USER}:${DB_PASS}@db/${DB_NAME:-app_${DB_USER}}\"\nregion = \"${EMPTY:-eu}\"\nprice = \"$$5\"\nhosts = [\"${HOST_A}\", \"${HOST_B}\"]\n\n[labels]\n\"${KEY}\" = \"x\"\n\n[[replicas]]\nhost = \"${HOST_A}\"\n",
+	}
+
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(dir, name)
+			mustWrite(t, file, content)
+
+			var c cfg
+			if err := Load(&c, WithFiles(file), WithInterpolation()); err != nil {
+				t.Fatalf("Load returned error: %v", err)
+			}
+			if c.DSN != `postgres://app:p"a:ss@db/app_app` || c.Region != "eu" || c.Price != "$5" {
+				t.Fatalf("unexpected expansion %+v", c)
+			}
+			if !reflect.DeepEqual(c.Hosts, []string{"a.internal", ""}) || c.Labels["${KEY}"] != "x" || c.Replicas[0].Host != "a.internal" {
+				t.Fatalf("unexpected nested expansion %+v", c)
+			}
+
+			if err := Load(&c, WithFiles(file)); err != nil || c.Region != "${EMPTY:-eu}" {
+				t.Fatalf("expected values untouched without WithInterpolation, got %q (%v)", c.Region, err)
+			}
+		})
+	}
+}
+
+func TestGetConfWithInterpolation(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "app")
+	mustWrite(t, base+".yaml", "region: ${TEST_REGION}\n")
+	t.Setenv("TEST_REGION", "eu")
+
+	var c struct {
+		Region string `json:"region"`
+	}
+	if err := GetConf(base, &c, WithInterpolation()); err != nil || c.Region != "eu" {
+		t.Fatalf("expected GetConf to pass options through, got %+v (%v)", c, err)
+	}
+	if err := LoadConfigFileNoExt(&c, base); err != nil || c.Region != "${TEST_REGION}" {
+		t.Fatalf("expected no interpolation without the option, got %+v (%v)", c, err)
+	}
+}
+
+func TestWithInterpolationErrors(t *testing.T) {
+	type cfg struct {
+		Database struct {
+			Password string `json:"password"`
+		} `json:"database"`
+	}
+
+	t.Setenv("DB_PASS", "")
+	t.Setenv("REGION", "")
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.yaml")
+	cases := map[string]string{
+		"database:\n  password: ${DB_PASS:?set DB_PASS for ${REGION:-eu}}\n": file + ":2: database.password: DB_PASS: set DB_PASS for eu",
+		"database:\n  password: ${DB_PASS:?}\n":                              file + ":2: database.password: DB_PASS is not set",
+		"database:\n  password: ${DB PASS}\n":                                file + ":2: database.password: invalid reference ${DB PASS}",
+		"database:\n  password: ${DB_PASS\n":                                 file + `:2: database.password: unterminated reference in "${DB_PASS"`,
+	}
+
+	for content, want := range cases {
+		mustWrite(t, file, content)
+
+		var c cfg
+		err := Load(&c, WithFiles(file), WithInterpolation())
+		var interpolation *InterpolationError
+		if !errors.As(err, &interpolation) || interpolation.Key != "database.password" || interpolation.Line != 2 {
+			t.Fatalf("expected InterpolationError for database.password, got %v", err)
+		}
+		if err.Error() != "konfig: interpolate "+want {
+			t.Fatalf("unexpected message:\n got %s\nwant konfig: interpolate %s", err.Error(), want)
+		}
+	}
+}
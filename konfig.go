@@ -1,14 +1,17 @@
 package konfig
 
 import (
+	"encoding"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/BurntSushi/toml"
@@ -22,9 +25,26 @@ var ErrNoSources = errors.New("konfig: no configuration sources found")
 type Option func(*options)
 
 type options struct {
-	envPrefix string
-	files     []string
-	base      string
+	envPrefix        string
+	files            []string
+	base             string
+	defaultsAsSource bool
+	provenance       *Provenance
+	pollInterval     time.Duration
+	sources          []Source
+	decoders         map[string]Decoder
+	strict           bool
+	normalizeKeys    bool
+	interpolate      bool
+	includeRoot      string
+	profile          string
+	profileSet       bool
+	searchPaths      []string
+	searchSet        bool
+	mergedSearch     bool
+	onInclude        func(name string)
+	strayEnvWarn     func(StrayEnv)
+	strayEnvErrors   bool
 }
 
 // WithEnvPrefix configures a prefix that is prepended to every generated
@@ -43,16 +63,67 @@ func WithFiles(files ...string) Option {
 	}
 }
 
-// withBase sets the base filename (without extension) used for implicit lookup.
-func withBase(base string) Option {
+// WithDefaultsAsSource makes values seeded from `default` struct tags count as
+// a configuration source, so Load does not return ErrNoSources when only
+// defaults were applied.
+func WithDefaultsAsSource() Option {
+	return func(o *options) {
+		o.defaultsAsSource = true
+	}
+}
+
+// WithProvenance records in report which source supplied each field and
+// which earlier sources it overrode. The report is filled in once every
+// source has been applied, before required fields and constraints are
+// checked, so it is available even when Load then fails validation.
+func WithProvenance(report *Provenance) Option {
+	return func(o *options) {
+		o.provenance = report
+	}
+}
+
+// WithBase sets a base filename (without extension) that is resolved to the
+// first of base.json, base.toml, base.yaml and base.yml that exists, followed
+// by any extension given to RegisterDecoder or WithDecoder. It is applied
+// before the files declared with WithFiles, and looked up in the directories
+// given to WithSearchPaths when there are any.
+func WithBase(base string) Option {
 	return func(o *options) {
 		o.base = base
 	}
 }
 
-// Load populates config by reading from the declared files and environment
-// variables, returning ErrNoSources when nothing supplies a value. The config
-// argument must be a non-nil pointer to a struct (or a struct of structs).
+// WithProfile layers profile-specific files over the WithBase file: after
+// base.<ext> come base.<profile>.<ext> and then base.local.<ext>, each
+// resolved by extension like the base file and skipped when missing, before
+// any WithFiles files and environment variables. An empty profile is read
+// from the PROFILE environment variable under the env prefix, such as
+// APP_PROFILE; when that is unset too, only the local file is added.
+func WithProfile(profile string) Option {
+	return func(o *options) {
+		o.profile = profile
+		o.profileSet = true
+	}
+}
+
+// WithNormalizedKeys matches file keys to fields ignoring case and the
+// separators `_`, `-` and `.`, so max_conns, maxConns and max-conns all bind
+// to MaxConns. Exact and case-insensitive matches are still preferred, and
+// Load fails when two keys of the same table match one field.
+func WithNormalizedKeys() Option {
+	return func(o *options) {
+		o.normalizeKeys = true
+	}
+}
+
+// Load populates config by seeding defaults (Defaulter hooks, then `default`
+// struct tags) and then applying its sources in order: the WithBase file, the
+// WithFiles files and environment variables, or the chain given to
+// WithSources. It returns ErrNoSources when nothing supplies a value. Once every
+// source is applied, required fields are checked, AfterLoader hooks run, and
+// `validate` tag constraints and Validator hooks are evaluated together. The
+// config argument must be a non-nil pointer to a struct (or a struct of
+// structs), and it is only modified if Load succeeds.
 func Load(config interface{}, opts ...Option) error {
 	if config == nil {
 		return errors.New("konfig: config must not be nil")
@@ -62,58 +133,117 @@ func Load(config interface{}, opts ...Option) error {
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return errors.New("konfig: config must be a non-nil pointer")
 	}
+	if rv.Elem().Kind() != reflect.Struct {
+		return errors.New("konfig: config must be a pointer to struct")
+	}
 
 	cfg := options{}
 	for _, opt := range opts {
 		opt(&cfg)
 	}
 
+	// Everything is applied to a deep copy that only replaces the caller's
+	// value once every source, hook and check has succeeded.
+	scratch := deepCopy(rv.Elem()).Addr()
+	if err := load(scratch, cfg); err != nil {
+		return err
+	}
+
+	rv.Elem().Set(scratch.Elem())
+	return nil
+}
+
+// load applies every source and check in cfg to the struct rv points to.
+func load(rv reflect.Value, cfg options) error {
+	sources, err := cfg.pipeline()
+	if err != nil {
+		return err
+	}
+	if err := checkDefinition(rv.Elem().Type(), sources); err != nil {
+		return err
+	}
+
 	var loaded bool
+	seen := newOrigins()
+	seen.positions = cfg.provenance != nil
 
-	if cfg.base != "" {
-		baseFiles := []string{
-			cfg.base + ".json",
-			cfg.base + ".toml",
-			cfg.base + ".yaml",
-			cfg.base + ".yml",
-		}
-		baseLoaded, err := loadFirstAvailable(baseFiles, config)
-		if err != nil {
+	runDefaulters(rv.Elem())
+
+	defaulted, err := setStructFieldsFromDefaults(rv.Elem(), "", seen)
+	if err != nil {
+		return err
+	}
+	loaded = cfg.defaultsAsSource && defaulted > 0
+
+	layer := &Layer{
+		target:        rv.Elem(),
+		seen:          seen,
+		decoders:      cfg.decoders,
+		strict:        cfg.strict,
+		normalizeKeys: cfg.normalizeKeys,
+		interpolate:   cfg.interpolate,
+		includeRoot:   cfg.includeRoot,
+		onInclude:     cfg.onInclude,
+	}
+	for _, source := range sources {
+		layer.name = source.Name()
+		if err := source.Load(layer); err != nil {
 			return err
 		}
-		loaded = loaded || baseLoaded
 	}
+	if len(layer.unknown) > 0 {
+		return &UnknownKeyError{Keys: layer.unknown}
+	}
+	if err := checkStrayEnv(rv.Elem().Type(), sources, cfg); err != nil {
+		return err
+	}
+	if err := resolveReferences(rv.Elem(), layer.pending, seen); err != nil {
+		return err
+	}
+	loaded = loaded || layer.supplied
 
-	if len(cfg.files) > 0 {
-		fileLoaded, err := loadSequential(cfg.files, config)
-		if err != nil {
-			return err
-		}
-		loaded = loaded || fileLoaded
+	if cfg.provenance != nil {
+		*cfg.provenance = seen.provenance()
+	}
+
+	if !loaded {
+		return ErrNoSources
+	}
+
+	prefix, withEnv := envPrefix(sources)
+	if err := checkRequired(rv.Elem(), prefix, withEnv, seen); err != nil {
+		return err
 	}
 
-	applied, err := applyEnvOverrides(rv, cfg.envPrefix)
+	if err := runAfterLoaders(rv.Elem()); err != nil {
+		return err
+	}
+
+	violations, err := checkConstraints(rv.Elem(), seen)
 	if err != nil {
 		return err
 	}
 
-	if !loaded && applied == 0 {
-		return ErrNoSources
+	var constraintErr error
+	if len(violations) > 0 {
+		constraintErr = &ValidationError{Violations: violations}
 	}
 
-	return nil
+	return errors.Join(constraintErr, runValidators(rv.Elem()))
 }
 
 // GetConf preserves the legacy API of resolving a base filename (without
 // extension) and populating config based on the first available source.
-func GetConf(base string, config interface{}) error {
-	return Load(config, withBase(base))
+// Options such as WithInterpolation are applied as they are by Load.
+func GetConf(base string, config interface{}, opts ...Option) error {
+	return Load(config, append([]Option{WithBase(base)}, opts...)...)
 }
 
 // LoadConfigFileNoExt attempts to load configuration using a base filename,
-// trying JSON, TOML, then YAML in that order.
-func LoadConfigFileNoExt(config interface{}, base string) error {
-	return Load(config, withBase(base))
+// trying JSON, TOML, then YAML in that order. Options are applied as they are
+// by Load.
+func LoadConfigFileNoExt(config interface{}, base string, opts ...Option) error {
+	return Load(config, append([]Option{WithBase(base)}, opts...)...)
 }
 
 // LoadConfigFiles sequentially loads the provided files, allowing later files
@@ -151,24 +281,24 @@ func GetConfigFilesWithExt(files ...string) ([]string, error) {
 
 // LoadJSON reads and unmarshals a JSON configuration file into configuration.
 func LoadJSON(filename string, configuration interface{}) error {
-	return decodeFile(filename, configuration, json.Unmarshal)
+	return decodeFile(filename, configuration, formatJSON)
 }
 
 // LoadTOML reads and unmarshals a TOML configuration file into configuration.
 func LoadTOML(filename string, configuration interface{}) error {
-	return decodeFile(filename, configuration, toml.Unmarshal)
+	return decodeFile(filename, configuration, formatTOML)
 }
 
 // LoadYAML reads and unmarshals a YAML configuration file into configuration.
 func LoadYAML(filename string, configuration interface{}) error {
-	return decodeFile(filename, configuration, unmarshalYAML)
+	return decodeFile(filename, configuration, formatYAML)
 }
 
 func unmarshalYAML(data []byte, target interface{}) error {
 	return yaml.Unmarshal(data, target)
 }
 
-func decodeFile(filename string, target interface{}, unmarshal func([]byte, interface{}) error) error {
+func decodeFile(filename string, target interface{}, format string) error {
 	if filename == "" {
 		return nil
 	}
@@ -178,29 +308,69 @@ func decodeFile(filename string, target interface{}, unmarshal func([]byte, inte
 		return fmt.Errorf("konfig: read %s: %w", filename, err)
 	}
 
-	if err := unmarshal(data, target); err != nil {
+	if err := unmarshalFormat(filename, format, data, target); err != nil {
 		return fmt.Errorf("konfig: decode %s: %w", filename, err)
 	}
 
 	return nil
 }
 
-func loadFirstAvailable(files []string, config interface{}) (bool, error) {
+// unmarshalFormat decodes data into target. Struct targets are bound through
+// the generic key tree so durations, timestamps and locations are parsed the
+// same way as environment values; anything else goes to the format's decoder.
+func unmarshalFormat(file, format string, data []byte, target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() && rv.Elem().Kind() == reflect.Struct {
+		tree, err := decodeTree(format, data)
+		if err != nil {
+			return err
+		}
+		return bindTree(rv.Elem(), tree, format, Origin{Kind: OriginFile, Name: file}, nil, nil)
+	}
+
+	switch format {
+	case formatJSON:
+		return json.Unmarshal(data, target)
+	case formatTOML:
+		return toml.Unmarshal(data, target)
+	default:
+		return unmarshalYAML(data, target)
+	}
+}
+
+// baseFiles lists the candidates a base filename resolves to, in lookup order:
+// the built-in extensions followed by any extra ones a decoder handles.
+func baseFiles(base string, extra []string) []string {
+	files := []string{
+		base + ".json",
+		base + ".toml",
+		base + ".yaml",
+		base + ".yml",
+	}
+	for _, ext := range extra {
+		files = append(files, base+ext)
+	}
+	return files
+}
+
+// loadFirstAvailable decodes the first of files that exists, reading from fsys
+// or, when fsys is nil, the operating system.
+func loadFirstAvailable(fsys fs.FS, files []string, layer *Layer) (bool, error) {
 	for _, file := range files {
 		file = strings.TrimSpace(file)
 		if file == "" {
 			continue
 		}
 
-		data, err := os.ReadFile(file)
+		data, err := readFile(fsys, file)
 		if err != nil {
-			if errors.Is(err, os.ErrNotExist) {
+			if errors.Is(err, fs.ErrNotExist) {
 				continue
 			}
 			return false, fmt.Errorf("konfig: read %s: %w", file, err)
 		}
 
-		if err := unmarshalByExtension(file, data, config); err != nil {
+		if err := layer.decode(fsys, nil, file, data); err != nil {
 			return false, err
 		}
 
@@ -210,7 +380,9 @@ func loadFirstAvailable(files []string, config interface{}) (bool, error) {
 	return false, nil
 }
 
-func loadSequential(files []string, config interface{}) (bool, error) {
+// loadSequential decodes every one of files that exists, in order, reading
+// from fsys or, when fsys is nil, the operating system.
+func loadSequential(fsys fs.FS, files []string, layer *Layer) (bool, error) {
 	var loaded bool
 
 	for _, file := range files {
@@ -219,15 +391,15 @@ func loadSequential(files []string, config interface{}) (bool, error) {
 			continue
 		}
 
-		data, err := os.ReadFile(file)
+		data, err := readFile(fsys, file)
 		if err != nil {
-			if errors.Is(err, os.ErrNotExist) {
+			if errors.Is(err, fs.ErrNotExist) {
 				continue
 			}
 			return loaded, fmt.Errorf("konfig: read %s: %w", file, err)
 		}
 
-		if err := unmarshalByExtension(file, data, config); err != nil {
+		if err := layer.decode(fsys, nil, file, data); err != nil {
 			return loaded, err
 		}
 
@@ -237,43 +409,23 @@ func loadSequential(files []string, config interface{}) (bool, error) {
 	return loaded, nil
 }
 
-func unmarshalByExtension(file string, data []byte, config interface{}) error {
-	switch ext := strings.ToLower(filepath.Ext(file)); ext {
-	case ".json":
-		if err := json.Unmarshal(data, config); err != nil {
-			return fmt.Errorf("konfig: decode %s: %w", file, err)
-		}
-	case ".toml":
-		if err := toml.Unmarshal(data, config); err != nil {
-			return fmt.Errorf("konfig: decode %s: %w", file, err)
-		}
-	case ".yaml", ".yml":
-		if err := unmarshalYAML(data, config); err != nil {
-			return fmt.Errorf("konfig: decode %s: %w", file, err)
-		}
-	default:
-		if err := tryFallbackDecoders(data, config); err != nil {
-			return fmt.Errorf("konfig: decode %s: %w", file, err)
-		}
+func readFile(fsys fs.FS, name string) ([]byte, error) {
+	if fsys == nil {
+		return os.ReadFile(name)
 	}
-
-	return nil
+	return fs.ReadFile(fsys, name)
 }
 
-func tryFallbackDecoders(data []byte, config interface{}) error {
-	if err := toml.Unmarshal(data, config); err == nil {
-		return nil
-	}
-	if err := json.Unmarshal(data, config); err == nil {
-		return nil
-	}
-	if err := unmarshalYAML(data, config); err == nil {
-		return nil
+func tryFallbackDecoders(data []byte) (string, map[string]interface{}, error) {
+	for _, format := range []string{formatTOML, formatJSON, formatYAML} {
+		if tree, err := decodeTree(format, data); err == nil {
+			return format, tree, nil
+		}
 	}
-	return errors.New("konfig: failed to decode configuration data")
+	return "", nil, errors.New("konfig: failed to decode configuration data")
 }
 
-func applyEnvOverrides(rv reflect.Value, prefix string) (int, error) {
+func applyEnvOverrides(rv reflect.Value, prefix string, seen *origins) (int, error) {
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return 0, errors.New("konfig: env overrides require a struct pointer")
 	}
@@ -283,10 +435,10 @@ func applyEnvOverrides(rv reflect.Value, prefix string) (int, error) {
 		return 0, errors.New("konfig: env overrides require a pointer to struct")
 	}
 
-	return setStructFieldsFromEnv(elem, prefix)
+	return setStructFieldsFromEnv(elem, prefix, "", seen)
 }
 
-func setStructFieldsFromEnv(structValue reflect.Value, prefix string) (int, error) {
+func setStructFieldsFromEnv(structValue reflect.Value, prefix, path string, seen *origins) (int, error) {
 	var applied int
 	structType := structValue.Type()
 
@@ -301,9 +453,23 @@ func setStructFieldsFromEnv(structValue reflect.Value, prefix string) (int, erro
 		if !ok {
 			continue
 		}
+		fieldPath := joinPath(path, fieldType.Name)
 
-		if fieldValue.Kind() == reflect.Struct {
-			nestedCount, err := setStructFieldsFromEnv(fieldValue, key)
+		// KEY_UNSET clears the field first; variables for it still apply.
+		unset, err := envUnset(key)
+		if err != nil {
+			return applied, err
+		}
+		if unset {
+			fieldValue.Set(reflect.Zero(fieldValue.Type()))
+			seen.clear(fieldPath, Origin{Kind: OriginEnv, Name: key + envUnsetSuffix, Unset: true})
+			applied++
+		}
+
+		_, asJSON := tagOption(fieldType.Tag, "json")
+
+		if fieldValue.Kind() == reflect.Struct && !isLeafStruct(fieldValue.Type()) && !asJSON {
+			nestedCount, err := setStructFieldsFromEnv(fieldValue, key, fieldPath, seen)
 			if err != nil {
 				return applied, err
 			}
@@ -311,11 +477,25 @@ func setStructFieldsFromEnv(structValue reflect.Value, prefix string) (int, erro
 			continue
 		}
 
-		if fieldValue.Kind() == reflect.Ptr && fieldValue.Type().Elem().Kind() == reflect.Struct {
+		if fieldValue.Kind() == reflect.Ptr && fieldValue.Type().Elem().Kind() == reflect.Struct && !isLeafStruct(fieldValue.Type().Elem()) && !asJSON {
+			// Only allocate nil pointers when a variable targets something beneath them.
+			target := fieldValue
 			if fieldValue.IsNil() {
-				fieldValue.Set(reflect.New(fieldValue.Type().Elem()))
+				target = reflect.New(fieldValue.Type().Elem())
+			}
+			nestedCount, err := setStructFieldsFromEnv(target.Elem(), key, fieldPath, seen)
+			if err != nil {
+				return applied, err
+			}
+			if nestedCount > 0 && fieldValue.IsNil() {
+				fieldValue.Set(target)
 			}
-			nestedCount, err := setStructFieldsFromEnv(fieldValue.Elem(), key)
+			applied += nestedCount
+			continue
+		}
+
+		if structElem(fieldValue.Type()) != nil && !asJSON {
+			nestedCount, err := setCollectionFromEnv(fieldValue, key, fieldPath, seen)
 			if err != nil {
 				return applied, err
 			}
@@ -328,16 +508,82 @@ func setStructFieldsFromEnv(structValue reflect.Value, prefix string) (int, erro
 			continue
 		}
 
-		if err := assignFromString(fieldValue, value); err != nil {
+		if err := assignFromStringWithTag(fieldValue, value, fieldType.Tag); err != nil {
 			return applied, fmt.Errorf("konfig: set %s: %w", key, err)
 		}
 
+		seen.record(fieldPath, Origin{Kind: OriginEnv, Name: key})
+		applied++
+	}
+
+	return applied, nil
+}
+
+// setStructFieldsFromDefaults assigns the `default` tag of every zero-valued
+// field, descending into nested structs the same way environment overrides do.
+func setStructFieldsFromDefaults(structValue reflect.Value, path string, seen *origins) (int, error) {
+	var applied int
+	structType := structValue.Type()
+
+	for i := 0; i < structValue.NumField(); i++ {
+		fieldType := structType.Field(i)
+		if !fieldType.IsExported() {
+			continue
+		}
+
+		fieldValue := structValue.Field(i)
+		fieldPath := joinPath(path, fieldType.Name)
+
+		if fieldValue.Kind() == reflect.Struct && !isLeafStruct(fieldValue.Type()) {
+			nestedCount, err := setStructFieldsFromDefaults(fieldValue, fieldPath, seen)
+			if err != nil {
+				return applied, err
+			}
+			applied += nestedCount
+			continue
+		}
+
+		if fieldValue.Kind() == reflect.Ptr && fieldValue.Type().Elem().Kind() == reflect.Struct && !isLeafStruct(fieldValue.Type().Elem()) {
+			// Only allocate nil pointers when something beneath them has a default.
+			target := fieldValue
+			if fieldValue.IsNil() {
+				target = reflect.New(fieldValue.Type().Elem())
+			}
+			nestedCount, err := setStructFieldsFromDefaults(target.Elem(), fieldPath, seen)
+			if err != nil {
+				return applied, err
+			}
+			if nestedCount > 0 && fieldValue.IsNil() {
+				fieldValue.Set(target)
+			}
+			applied += nestedCount
+			continue
+		}
+
+		value, ok := fieldType.Tag.Lookup("default")
+		if !ok || !fieldValue.IsZero() {
+			continue
+		}
+
+		if err := assignFromStringWithTag(fieldValue, value, fieldType.Tag); err != nil {
+			return applied, fmt.Errorf("konfig: default %s: %w", fieldPath, err)
+		}
+
+		seen.record(fieldPath, Origin{Kind: OriginDefault})
 		applied++
 	}
 
 	return applied, nil
 }
 
+// joinPath appends name to a dotted field path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
 func envKey(field reflect.StructField, prefix string) (string, bool) {
 	tag := field.Tag.Get("env")
 	if tag == "-" {
@@ -350,6 +596,9 @@ func envKey(field reflect.StructField, prefix string) (string, bool) {
 
 	name := tag
 	if name == "" {
+		if field.Tag.Get("konfig") == "-" {
+			return "", false
+		}
 		name = firstNonEmptyTagValue(field, "konfig", "json", "yaml", "toml")
 	}
 	if name == "" {
@@ -368,6 +617,28 @@ func envKey(field reflect.StructField, prefix string) (string, bool) {
 	return key, true
 }
 
+// hasTagOption reports whether option follows the name in the field's env or
+// konfig tag, as in `env:"DB_URL,required"` or `konfig:",required"`.
+func hasTagOption(field reflect.StructField, option string) bool {
+	_, ok := tagOption(field.Tag, option)
+	return ok
+}
+
+// tagOption looks up an option in the env or konfig tag, returning the text
+// after "=" for options written as `env:"HOSTS,sep=;"`.
+func tagOption(tag reflect.StructTag, option string) (string, bool) {
+	for _, name := range []string{"env", "konfig"} {
+		parts := strings.Split(tag.Get(name), ",")
+		for _, part := range parts[1:] {
+			key, value, _ := strings.Cut(strings.TrimLeft(part, " "), "=")
+			if strings.TrimSpace(key) == option {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
+
 func firstNonEmptyTagValue(field reflect.StructField, names ...string) string {
 	for _, name := range names {
 		tag := field.Tag.Get(name)
@@ -447,7 +718,136 @@ func isBoundary(prev rune, current rune) bool {
 	return false
 }
 
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	locationType        = reflect.TypeOf(time.Location{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	flagValueType       = reflect.TypeOf((*flag.Value)(nil)).Elem()
+)
+
+// isLeafStruct reports whether a struct type is assigned as a single value
+// rather than descended into field by field: the time types and anything
+// that parses itself through encoding.TextUnmarshaler or flag.Value.
+func isLeafStruct(t reflect.Type) bool {
+	return t == timeType || t == locationType || isTextType(t)
+}
+
+func isTextType(t reflect.Type) bool {
+	ptr := reflect.PointerTo(t)
+	return t.Implements(textUnmarshalerType) || ptr.Implements(textUnmarshalerType) ||
+		t.Implements(flagValueType) || ptr.Implements(flagValueType)
+}
+
+// assignText hands value to the field's UnmarshalText or flag.Value Set
+// method. It reports false when the field's type implements neither.
+//
+// The method runs on a fresh value that is then stored in the field, because
+// types such as *big.Int reuse memory held in unexported fields, which Load's
+// working copy still shares with the caller's struct.
+func assignText(field reflect.Value, value string) (bool, error) {
+	var fresh reflect.Value
+	switch {
+	case field.Kind() == reflect.Ptr && isTextType(field.Type().Elem()):
+		fresh = reflect.New(field.Type().Elem())
+	case field.CanSet() && isTextType(field.Type()):
+		fresh = reflect.New(field.Type())
+	default:
+		return false, nil
+	}
+
+	var err error
+	switch t := fresh.Interface().(type) {
+	case encoding.TextUnmarshaler:
+		err = t.UnmarshalText([]byte(value))
+	case flag.Value:
+		err = t.Set(value)
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+
+	if field.Kind() == reflect.Ptr {
+		field.Set(fresh)
+	} else {
+		field.Set(fresh.Elem())
+	}
+	return true, nil
+}
+
+// assignTimeString parses value into time.Duration, time.Time (using the
+// field's `layout` tag, RFC 3339 by default) and *time.Location fields,
+// allocating pointers as needed. It reports false for any other field type.
+func assignTimeString(field reflect.Value, value string, tag reflect.StructTag) (bool, error) {
+	target := field.Type()
+	if target.Kind() == reflect.Ptr {
+		target = target.Elem()
+	}
+
+	switch target {
+	case locationType:
+		if field.Kind() != reflect.Ptr {
+			return false, nil
+		}
+		loc, err := time.LoadLocation(value)
+		if err != nil {
+			return true, err
+		}
+		field.Set(reflect.ValueOf(loc))
+		return true, nil
+	case durationType, timeType:
+	default:
+		return false, nil
+	}
+
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(target))
+		}
+		field = field.Elem()
+	}
+
+	if target == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return true, err
+		}
+		field.SetInt(int64(d))
+		return true, nil
+	}
+
+	layout := tag.Get("layout")
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return true, err
+	}
+	field.Set(reflect.ValueOf(t))
+	return true, nil
+}
+
 func assignFromString(field reflect.Value, value string) error {
+	return assignFromStringWithTag(field, value, "")
+}
+
+// assignFromStringWithTag converts value for field, honouring per-field
+// options such as the `layout` used for timestamps and the separator used for
+// slices and maps.
+func assignFromStringWithTag(field reflect.Value, value string, tag reflect.StructTag) error {
+	if _, ok := tagOption(tag, "json"); ok {
+		return assignJSONString(field, value, tag)
+	}
+	if handled, err := assignTimeString(field, value, tag); handled {
+		return err
+	}
+	if handled, err := assignText(field, value); handled {
+		return err
+	}
+
 	if field.Kind() == reflect.Ptr {
 		if field.IsNil() {
 			field.Set(reflect.New(field.Type().Elem()))
@@ -486,9 +886,89 @@ func assignFromString(field reflect.Value, value string) error {
 			return err
 		}
 		field.SetFloat(v)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.Uint8 {
+			field.SetBytes([]byte(value))
+			return nil
+		}
+		parts := splitList(value, tag)
+		slice := reflect.MakeSlice(field.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := assignFromStringWithTag(slice.Index(i), part, tag); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		field.Set(slice)
+	case reflect.Array:
+		parts := splitList(value, tag)
+		if len(parts) > field.Len() {
+			return fmt.Errorf("%d elements exceed array length %d", len(parts), field.Len())
+		}
+		array := reflect.New(field.Type()).Elem()
+		for i, part := range parts {
+			if err := assignFromStringWithTag(array.Index(i), part, tag); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		field.Set(array)
+	case reflect.Map:
+		mapType := field.Type()
+		parts := splitList(value, tag)
+		entries := reflect.MakeMapWithSize(mapType, len(parts))
+		for _, part := range parts {
+			k, v, ok := strings.Cut(part, "=")
+			if !ok {
+				return fmt.Errorf("entry %q is not a key=value pair", part)
+			}
+			key := reflect.New(mapType.Key()).Elem()
+			if err := assignMapKey(key, strings.TrimSpace(k)); err != nil {
+				return fmt.Errorf("key %q: %w", k, err)
+			}
+			elem := reflect.New(mapType.Elem()).Elem()
+			if err := assignFromStringWithTag(elem, strings.TrimSpace(v), tag); err != nil {
+				return fmt.Errorf("key %q: %w", k, err)
+			}
+			entries.SetMapIndex(key, elem)
+		}
+		field.Set(entries)
 	default:
 		return fmt.Errorf("unsupported kind %s", field.Kind())
 	}
 
 	return nil
 }
+
+// splitList splits a slice or map value on the field's `sep` option (a comma
+// by default), trimming whitespace around each element. An empty value yields
+// no elements.
+func splitList(value string, tag reflect.StructTag) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	sep, ok := tagOption(tag, "sep")
+	if !ok || sep == "" {
+		sep = ","
+	}
+
+	parts := strings.Split(value, sep)
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return parts
+}
+
+// assignJSONString decodes value as JSON for fields tagged with the json
+// option and binds it with the same conversions used for JSON files.
+func assignJSONString(field reflect.Value, value string, tag reflect.StructTag) error {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return fmt.Errorf("decode json: %w", err)
+	}
+
+	b := binder{format: formatJSON, keys: map[string]string{}}
+	return b.bind(field, decoded, "", tag)
+}
@@ -2,11 +2,16 @@ package konfig
 
 import (
 	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 type sampleDB struct {
@@ -92,7 +97,7 @@ func TestLoadEnvironmentOverrides(t *testing.T) {
 	}
 
 	var cfg config
-	if err := Load(&cfg, withBase(base), WithEnvPrefix("APP")); err != nil {
+	if err := Load(&cfg, WithBase(base), WithEnvPrefix("APP")); err != nil {
 		t.Fatalf("Load returned error: %v", err)
 	}
 
@@ -123,6 +128,30 @@ func TestLoadEnvironmentOnly(t *testing.T) {
 	}
 }
 
+func TestLoadEnvAllocatesPointerSectionsOnlyWhenSet(t *testing.T) {
+	type section struct {
+		Host string
+	}
+	var cfg struct {
+		Server string
+		DB     *section
+		Cache  *section
+	}
+
+	t.Setenv("CONFIG_SERVER", "svc")
+	t.Setenv("CONFIG_CACHE_HOST", "cache")
+
+	if err := Load(&cfg, WithEnvPrefix("CONFIG")); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.DB != nil {
+		t.Fatalf("expected untouched section to stay nil, got %+v", cfg.DB)
+	}
+	if cfg.Cache == nil || cfg.Cache.Host != "cache" {
+		t.Fatalf("expected section allocated for its variable, got %+v", cfg.Cache)
+	}
+}
+
 func TestLoadNoSources(t *testing.T) {
 	var cfg struct {
 		Server string
@@ -225,7 +254,7 @@ func TestLoadSequentialIgnoresBlank(t *testing.T) {
 	mustWrite(t, valid, `{"Server":"ok"}`)
 
 	var cfg struct{ Server string }
-	loaded, err := loadSequential([]string{"   ", valid}, &cfg)
+	loaded, err := loadSequential(nil, []string{"   ", valid}, &Layer{target: reflect.ValueOf(&cfg).Elem()})
 	if err != nil {
 		t.Fatalf("loadSequential error: %v", err)
 	}
@@ -243,7 +272,7 @@ func TestLoadFirstAvailableSkipsBlank(t *testing.T) {
 	mustWrite(t, valid, `{"Server":"ok"}`)
 
 	var cfg struct{ Server string }
-	loaded, err := loadFirstAvailable([]string{"   ", valid}, &cfg)
+	loaded, err := loadFirstAvailable(nil, []string{"   ", valid}, &Layer{target: reflect.ValueOf(&cfg).Elem()})
 	if err != nil {
 		t.Fatalf("loadFirstAvailable error: %v", err)
 	}
@@ -420,7 +449,7 @@ func TestAssignFromStringConversions(t *testing.T) {
 		F   float64
 		PI  *int
 		PF  *float32
-		Bad []string
+		Bad complex128
 	}
 
 	var s sample
@@ -465,12 +494,12 @@ func TestAssignFromStringConversions(t *testing.T) {
 
 func TestApplyEnvOverridesErrors(t *testing.T) {
 	var ptr *struct{}
-	if _, err := applyEnvOverrides(reflect.ValueOf(ptr), ""); err == nil {
+	if _, err := applyEnvOverrides(reflect.ValueOf(ptr), "", nil); err == nil {
 		t.Fatalf("expected error on nil pointer")
 	}
 
 	var notStruct = new(int)
-	if _, err := applyEnvOverrides(reflect.ValueOf(notStruct), ""); err == nil {
+	if _, err := applyEnvOverrides(reflect.ValueOf(notStruct), "", nil); err == nil {
 		t.Fatalf("expected error for non-struct pointer")
 	}
 }
@@ -520,7 +549,7 @@ func TestEnvKeyEmpty(t *testing.T) {
 
 func TestEnvOverrideAssignError(t *testing.T) {
 	type cfg struct {
-		Values []string
+		Values complex128
 	}
 
 	t.Setenv("CFG_VALUES", "oops")
@@ -641,7 +670,7 @@ func TestLoadBaseMissingUsesEnv(t *testing.T) {
 	t.Setenv("APP_SERVER", "from-env")
 
 	var c cfg
-	if err := Load(&c, withBase(base), WithEnvPrefix("APP")); err != nil {
+	if err := Load(&c, WithBase(base), WithEnvPrefix("APP")); err != nil {
 		t.Fatalf("Load unexpectedly failed: %v", err)
 	}
 
@@ -743,6 +772,447 @@ func TestEnvOverridesNoPrefix(t *testing.T) {
 	}
 }
 
+func TestLoadDefaultsLayeredUnderSources(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	mustWrite(t, file, "Server: from-file\n")
+
+	type db struct {
+		Host string `default:"localhost"`
+		Port int    `default:"5432"`
+	}
+
+	type cfg struct {
+		Server   string `default:"default-server"`
+		Port     int    `default:"8080"`
+		Debug    bool   `default:"true"`
+		Timeout  *int   `default:"30"`
+		Database db
+		Cache    *struct {
+			Size int `default:"64"`
+		}
+	}
+
+	t.Setenv("APP_PORT", "9090")
+
+	var c cfg
+	if err := Load(&c, WithFiles(file), WithEnvPrefix("APP")); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if c.Server != "from-file" {
+		t.Fatalf("expected file to override default, got %q", c.Server)
+	}
+	if c.Port != 9090 {
+		t.Fatalf("expected env to override default, got %d", c.Port)
+	}
+	if !c.Debug {
+		t.Fatalf("expected debug default true")
+	}
+	if c.Timeout == nil || *c.Timeout != 30 {
+		t.Fatalf("expected timeout default 30, got %v", c.Timeout)
+	}
+	if c.Database.Host != "localhost" || c.Database.Port != 5432 {
+		t.Fatalf("expected nested defaults, got %+v", c.Database)
+	}
+	if c.Cache == nil || c.Cache.Size != 64 {
+		t.Fatalf("expected pointer struct defaults, got %#v", c.Cache)
+	}
+}
+
+func TestLoadDefaultsKeepExistingValues(t *testing.T) {
+	type cfg struct {
+		Server string `default:"default-server"`
+		Port   int    `default:"8080"`
+	}
+
+	c := cfg{Server: "preset"}
+	if err := Load(&c, WithDefaultsAsSource()); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if c.Server != "preset" {
+		t.Fatalf("expected preset value kept, got %q", c.Server)
+	}
+	if c.Port != 8080 {
+		t.Fatalf("expected default port, got %d", c.Port)
+	}
+}
+
+func TestLoadDefaultsAreNotASource(t *testing.T) {
+	type cfg struct {
+		Port int `default:"8080"`
+	}
+
+	var c cfg
+	if err := Load(&c); !errors.Is(err, ErrNoSources) {
+		t.Fatalf("expected ErrNoSources, got %v", err)
+	}
+
+	var withDefaults cfg
+	if err := Load(&withDefaults, WithDefaultsAsSource()); err != nil {
+		t.Fatalf("expected defaults to count as source, got %v", err)
+	}
+	if withDefaults.Port != 8080 {
+		t.Fatalf("expected default port, got %d", withDefaults.Port)
+	}
+}
+
+func TestLoadDefaultsParseError(t *testing.T) {
+	type cfg struct {
+		Nested struct {
+			Port int `default:"not-a-number"`
+		}
+	}
+
+	var c cfg
+	err := Load(&c, WithDefaultsAsSource())
+	if err == nil || !strings.Contains(err.Error(), "default Nested.Port") {
+		t.Fatalf("expected default parse error with path, got %v", err)
+	}
+}
+
+func TestEnvOverrideTimeValues(t *testing.T) {
+	type cfg struct {
+		Timeout  time.Duration
+		Retry    *time.Duration
+		Started  time.Time
+		Birthday *time.Time `layout:"2006-01-02"`
+		Zone     *time.Location
+		Window   struct {
+			Open time.Duration `default:"15m"`
+		}
+	}
+
+	t.Setenv("APP_TIMEOUT", "30s")
+	t.Setenv("APP_RETRY", "250ms")
+	t.Setenv("APP_STARTED", "2024-05-01T10:00:00+02:00")
+	t.Setenv("APP_BIRTHDAY", "1990-12-31")
+	t.Setenv("APP_ZONE", "America/New_York")
+
+	var c cfg
+	if err := Load(&c, WithEnvPrefix("APP")); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if c.Timeout != 30*time.Second {
+		t.Fatalf("expected 30s, got %v", c.Timeout)
+	}
+	if c.Retry == nil || *c.Retry != 250*time.Millisecond {
+		t.Fatalf("expected 250ms retry, got %v", c.Retry)
+	}
+	if c.Started.Hour() != 10 || c.Started.UTC().Hour() != 8 {
+		t.Fatalf("unexpected start %v", c.Started)
+	}
+	if c.Birthday == nil || c.Birthday.Year() != 1990 {
+		t.Fatalf("unexpected birthday %v", c.Birthday)
+	}
+	if c.Zone == nil || c.Zone.String() != "America/New_York" {
+		t.Fatalf("unexpected zone %v", c.Zone)
+	}
+	if c.Window.Open != 15*time.Minute {
+		t.Fatalf("expected default duration, got %v", c.Window.Open)
+	}
+}
+
+func TestEnvOverrideTimeErrors(t *testing.T) {
+	type cfg struct {
+		Timeout time.Duration
+	}
+
+	t.Setenv("APP_TIMEOUT", "30")
+
+	var c cfg
+	err := Load(&c, WithEnvPrefix("APP"))
+	if err == nil || !strings.Contains(err.Error(), "set APP_TIMEOUT: time: missing unit") {
+		t.Fatalf("expected duration parse error, got %v", err)
+	}
+}
+
+func TestLoadDurationConstraints(t *testing.T) {
+	type cfg struct {
+		Timeout time.Duration `validate:"min=1s,max=1m"`
+	}
+
+	t.Setenv("APP_TIMEOUT", "2m")
+
+	var c cfg
+	err := Load(&c, WithEnvPrefix("APP"))
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Violations[0].Constraint != "max=1m" {
+		t.Fatalf("expected max=1m violation, got %v", err)
+	}
+}
+
+type logLevel struct {
+	name string
+}
+
+func (l *logLevel) UnmarshalText(text []byte) error {
+	switch name := strings.ToLower(string(text)); name {
+	case "debug", "info":
+		l.name = name
+		return nil
+	}
+	return fmt.Errorf("unknown level %q", text)
+}
+
+type hostList []string
+
+func (h *hostList) String() string {
+	return strings.Join(*h, ";")
+}
+
+func (h *hostList) Set(value string) error {
+	*h = strings.Split(value, ";")
+	return nil
+}
+
+func TestEnvOverrideTextUnmarshalers(t *testing.T) {
+	type cfg struct {
+		Addr     netip.Addr
+		Prefix   *netip.Prefix
+		Big      *big.Int
+		Slog     slog.Level
+		Level    logLevel
+		Fallback logLevel `default:"info"`
+		Hosts    hostList
+	}
+
+	t.Setenv("APP_ADDR", "192.168.1.10")
+	t.Setenv("APP_PREFIX", "10.0.0.0/8")
+	t.Setenv("APP_BIG", "123456789012345678901234567890")
+	t.Setenv("APP_SLOG", "WARN")
+	t.Setenv("APP_LEVEL", "DEBUG")
+	t.Setenv("APP_HOSTS", "a;b")
+
+	var c cfg
+	if err := Load(&c, WithEnvPrefix("APP")); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if c.Addr != netip.MustParseAddr("192.168.1.10") {
+		t.Fatalf("unexpected addr %v", c.Addr)
+	}
+	if c.Prefix == nil || c.Prefix.String() != "10.0.0.0/8" {
+		t.Fatalf("unexpected prefix %v", c.Prefix)
+	}
+	if c.Big == nil || c.Big.String() != "123456789012345678901234567890" {
+		t.Fatalf("unexpected big int %v", c.Big)
+	}
+	if c.Slog != slog.LevelWarn {
+		t.Fatalf("unexpected slog level %v", c.Slog)
+	}
+	if c.Level.name != "debug" || c.Fallback.name != "info" {
+		t.Fatalf("unexpected levels %+v %+v", c.Level, c.Fallback)
+	}
+	if len(c.Hosts) != 2 || c.Hosts[1] != "b" {
+		t.Fatalf("expected flag.Value to parse hosts, got %v", c.Hosts)
+	}
+}
+
+func TestEnvOverrideTextUnmarshalerError(t *testing.T) {
+	type cfg struct {
+		Level logLevel
+	}
+
+	t.Setenv("APP_LEVEL", "verbose")
+
+	var c cfg
+	err := Load(&c, WithEnvPrefix("APP"))
+	if err == nil || !strings.Contains(err.Error(), `set APP_LEVEL: unknown level "verbose"`) {
+		t.Fatalf("expected UnmarshalText error, got %v", err)
+	}
+}
+
+func TestEnvOverrideSlicesAndMaps(t *testing.T) {
+	type upstream struct {
+		Host string
+		Port int
+	}
+
+	type cfg struct {
+		Origins   []string
+		Brokers   []string `env:",sep=;"`
+		Ports     []int
+		Timeouts  []time.Duration
+		Addrs     []netip.Addr
+		Pair      [2]int
+		Labels    map[string]string
+		Weights   map[string]float64 `konfig:",sep=|"`
+		Empty     []string
+		Raw       []byte
+		Upstreams []upstream       `env:",json"`
+		Limits    map[string]int   `env:",json"`
+		Primary   upstream         `env:",json"`
+		Tags      []string         `default:"a,b"`
+		Extra     map[string][]int `env:",json"`
+	}
+
+	t.Setenv("APP_ORIGINS", "https://a.example, https://b.example")
+	t.Setenv("APP_BROKERS", "kafka-1:9092;kafka-2:9092")
+	t.Setenv("APP_PORTS", "80,443")
+	t.Setenv("APP_TIMEOUTS", "1s,2m")
+	t.Setenv("APP_ADDRS", "10.0.0.1,::1")
+	t.Setenv("APP_PAIR", "1,2")
+	t.Setenv("APP_LABELS", "env=prod, region=eu=west")
+	t.Setenv("APP_WEIGHTS", "a=0.5|b=1.5")
+	t.Setenv("APP_EMPTY", "")
+	t.Setenv("APP_RAW", "a,b")
+	t.Setenv("APP_UPSTREAMS", `[{"Host":"a","Port":80},{"Host":"b","Port":81}]`)
+	t.Setenv("APP_LIMITS", `{"cpu":2,"mem":512}`)
+	t.Setenv("APP_PRIMARY", `{"Host":"main","Port":9000}`)
+	t.Setenv("APP_EXTRA", `{"x":[1,2]}`)
+
+	var c cfg
+	if err := Load(&c, WithEnvPrefix("APP")); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if !reflect.DeepEqual(c.Origins, []string{"https://a.example", "https://b.example"}) {
+		t.Fatalf("unexpected origins %q", c.Origins)
+	}
+	if !reflect.DeepEqual(c.Brokers, []string{"kafka-1:9092", "kafka-2:9092"}) {
+		t.Fatalf("unexpected brokers %q", c.Brokers)
+	}
+	if !reflect.DeepEqual(c.Ports, []int{80, 443}) {
+		t.Fatalf("unexpected ports %v", c.Ports)
+	}
+	if !reflect.DeepEqual(c.Timeouts, []time.Duration{time.Second, 2 * time.Minute}) {
+		t.Fatalf("unexpected timeouts %v", c.Timeouts)
+	}
+	if len(c.Addrs) != 2 || !c.Addrs[1].Is6() {
+		t.Fatalf("unexpected addrs %v", c.Addrs)
+	}
+	if c.Pair != [2]int{1, 2} {
+		t.Fatalf("unexpected pair %v", c.Pair)
+	}
+	if !reflect.DeepEqual(c.Labels, map[string]string{"env": "prod", "region": "eu=west"}) {
+		t.Fatalf("unexpected labels %v", c.Labels)
+	}
+	if !reflect.DeepEqual(c.Weights, map[string]float64{"a": 0.5, "b": 1.5}) {
+		t.Fatalf("unexpected weights %v", c.Weights)
+	}
+	if c.Empty == nil || len(c.Empty) != 0 {
+		t.Fatalf("expected empty non-nil slice, got %#v", c.Empty)
+	}
+	if string(c.Raw) != "a,b" {
+		t.Fatalf("expected raw bytes, got %q", c.Raw)
+	}
+	if len(c.Upstreams) != 2 || c.Upstreams[1].Port != 81 {
+		t.Fatalf("unexpected upstreams %+v", c.Upstreams)
+	}
+	if !reflect.DeepEqual(c.Limits, map[string]int{"cpu": 2, "mem": 512}) {
+		t.Fatalf("unexpected limits %v", c.Limits)
+	}
+	if c.Primary.Host != "main" || c.Primary.Port != 9000 {
+		t.Fatalf("unexpected primary %+v", c.Primary)
+	}
+	if !reflect.DeepEqual(c.Tags, []string{"a", "b"}) {
+		t.Fatalf("unexpected default tags %v", c.Tags)
+	}
+	if !reflect.DeepEqual(c.Extra, map[string][]int{"x": {1, 2}}) {
+		t.Fatalf("unexpected extra %v", c.Extra)
+	}
+}
+
+func TestEnvOverrideSliceAndMapErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		config interface{}
+		value  string
+		want   string
+	}{
+		{"element", &struct{ Value []int }{}, "1,x", "set APP_VALUE: element 1: strconv.ParseInt"},
+		{"pair", &struct{ Value map[string]string }{}, "a=1,b", `set APP_VALUE: entry "b" is not a key=value pair`},
+		{"map value", &struct{ Value map[string]int }{}, "a=x", `set APP_VALUE: key "a": strconv.ParseInt`},
+		{"array length", &struct{ Value [1]int }{}, "1,2", "2 elements exceed array length 1"},
+		{"json syntax", &struct {
+			Value []int `env:",json"`
+		}{}, "[1,", "set APP_VALUE: decode json"},
+		{"json type", &struct {
+			Value []int `env:",json"`
+		}{}, `["a"]`, "set APP_VALUE: 0: cannot use string as int"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("APP_VALUE", tc.value)
+			err := Load(tc.config, WithEnvPrefix("APP"))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+type transactionalConfig struct {
+	Name     string `default:"svc"`
+	Workers  int    `validate:"min=1"`
+	Labels   map[string]string
+	Database *struct {
+		Host string
+	}
+	Hosts []string
+}
+
+func TestLoadLeavesConfigUntouchedOnError(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	bad := filepath.Join(dir, "bad.json")
+	invalid := filepath.Join(dir, "invalid.json")
+	mustWrite(t, good, `{"Workers":2,"Labels":{"tier":"web"},"Database":{"Host":"db"},"Hosts":["a"]}`)
+	mustWrite(t, bad, `{"Workers":"two"}`)
+	mustWrite(t, invalid, `{"Workers":0}`)
+
+	newConfig := func() transactionalConfig {
+		c := transactionalConfig{Workers: 1, Labels: map[string]string{"env": "dev"}, Hosts: []string{"orig"}}
+		c.Database = &struct{ Host string }{Host: "orig"}
+		return c
+	}
+
+	cases := []struct {
+		name  string
+		setup func(t *testing.T)
+		opts  []Option
+	}{
+		{"later file fails to decode", nil, []Option{WithFiles(good, bad)}},
+		{"env value fails to parse", func(t *testing.T) { t.Setenv("APP_WORKERS", "many") }, []Option{WithFiles(good), WithEnvPrefix("APP")}},
+		{"validation fails", nil, []Option{WithFiles(good, invalid)}},
+		{"no sources", nil, []Option{WithFiles(filepath.Join(dir, "missing.json"))}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.setup != nil {
+				tc.setup(t)
+			}
+
+			c := newConfig()
+			database := c.Database
+			if err := Load(&c, tc.opts...); err == nil {
+				t.Fatalf("expected Load to fail")
+			}
+
+			if !reflect.DeepEqual(c, newConfig()) {
+				t.Fatalf("expected config untouched, got %+v (database %+v)", c, c.Database)
+			}
+			if c.Database != database {
+				t.Fatalf("expected pointer fields left as they were")
+			}
+		})
+	}
+
+	c := newConfig()
+	if err := Load(&c, WithFiles(good)); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Name != "svc" || c.Workers != 2 || c.Labels["env"] != "dev" || c.Labels["tier"] != "web" || c.Database.Host != "db" {
+		t.Fatalf("expected successful load committed, got %+v", c)
+	}
+}
+
 func mustWrite(t *testing.T, filename, content string) {
 	t.Helper()
 	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
@@ -0,0 +1,109 @@
+package konfig
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// mergeStrategy says how a file or source layer combines a slice, map or
+// struct value with what earlier layers left in the field, as chosen by the
+// field's `merge` tag.
+type mergeStrategy string
+
+const (
+	// mergeDefault replaces slices, adds map keys replacing their values,
+	// and merges structs field by field.
+	mergeDefault mergeStrategy = ""
+	// mergeReplace discards the earlier value entirely.
+	mergeReplace mergeStrategy = "replace"
+	// mergeAppend appends slice elements and adds map keys.
+	mergeAppend mergeStrategy = "append"
+	// mergeDeep merges slice elements by index and map values by key.
+	mergeDeep mergeStrategy = "deep"
+	// mergeKeyed merges elements of a slice of structs that share the value
+	// of a key field, appending the others.
+	mergeKeyed mergeStrategy = "key"
+)
+
+// parseMergeTag reads the `merge` tag, returning the strategy and, for
+// `merge:"key=ID"`, the name of the key field.
+func parseMergeTag(tag reflect.StructTag) (mergeStrategy, string, error) {
+	value := strings.TrimSpace(tag.Get("merge"))
+	if name, ok := strings.CutPrefix(value, "key="); ok {
+		if name = strings.TrimSpace(name); name == "" {
+			return "", "", fmt.Errorf("merge tag %q names no key field", value)
+		}
+		return mergeKeyed, name, nil
+	}
+
+	switch strategy := mergeStrategy(value); strategy {
+	case mergeDefault, mergeReplace, mergeAppend, mergeDeep:
+		return strategy, "", nil
+	}
+	return "", "", fmt.Errorf("unknown merge strategy %q", value)
+}
+
+// mergeKeyField finds the field named by `merge:"key=..."` in the element type
+// of a slice, by Go field name or file key.
+func mergeKeyField(sliceType reflect.Type, name, format string) (fileField, error) {
+	elemType := sliceType.Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fileField{}, fmt.Errorf("keyed merge needs a slice of structs, not %s", sliceType)
+	}
+
+	for _, field := range fileFields(elemType, format) {
+		if field.path == name || field.name == name {
+			return field, nil
+		}
+	}
+	return fileField{}, fmt.Errorf("keyed merge: no field %q in %s", name, elemType)
+}
+
+// keyedIndex returns the index of the element of slice whose key field equals
+// the key in item, or -1 if item has no key or no element matches.
+func (b *binder) keyedIndex(slice reflect.Value, item interface{}, key fileField) int {
+	tree, ok := item.(map[string]interface{})
+	if !ok {
+		return -1
+	}
+
+	elemType := slice.Type().Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	fields := fileFields(elemType, b.format)
+
+	for _, name := range sortedKeys(tree) {
+		field, ok, _ := b.lookupField(fields, name)
+		if !ok || field.path != key.path {
+			continue
+		}
+
+		// Convert the raw key with a detached binder so nothing is recorded.
+		want := reflect.New(key.field.Type).Elem()
+		scratch := binder{format: b.format, keys: map[string]string{}}
+		if err := scratch.bind(want, tree[name], "", key.field.Tag); err != nil {
+			return -1
+		}
+
+		for i := 0; i < slice.Len(); i++ {
+			elem := slice.Index(i)
+			if elem.Kind() == reflect.Ptr {
+				if elem.IsNil() {
+					continue
+				}
+				elem = elem.Elem()
+			}
+			got, err := elem.FieldByIndexErr(key.index)
+			if err == nil && reflect.DeepEqual(got.Interface(), want.Interface()) {
+				return i
+			}
+		}
+		return -1
+	}
+	return -1
+}
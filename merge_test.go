@@ -0,0 +1,177 @@
+package konfig
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type mergeUpstream struct {
+	Name    string `json:"name" toml:"name"`
+	Host    string `json:"host" toml:"host"`
+	Weight  int    `json:"weight" toml:"weight"`
+	Enabled bool   `json:"enabled" toml:"enabled"`
+}
+
+type mergeDB struct {
+	Host string `json:"host" toml:"host"`
+	Port int    `json:"port" toml:"port"`
+}
+
+type mergeConfig struct {
+	Origins   []string           `json:"origins" toml:"origins"`
+	Plugins   []string           `json:"plugins" toml:"plugins" merge:"append"`
+	Ports     []mergeDB          `json:"ports" toml:"ports" merge:"deep"`
+	Upstreams []*mergeUpstream   `json:"upstreams" toml:"upstreams" merge:"key=name"`
+	DBs       map[string]mergeDB `json:"dbs" toml:"dbs" merge:"deep"`
+	Labels    map[string]string  `json:"labels" toml:"labels"`
+	Limits    map[string]int     `json:"limits" toml:"limits" merge:"replace"`
+	TLS       struct {
+		Cert string `json:"cert" toml:"cert"`
+		Key  string `json:"key" toml:"key"`
+	} `json:"tls" toml:"tls" merge:"replace"`
+}
+
+func TestLoadMergeStrategies(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "default.yaml")
+	override := filepath.Join(dir, "override.toml")
+	mustWrite(t, base, `origins: [a, b]
+plugins: [auth, metrics]
+ports:
+  - host: db-1
+    port: 5432
+  - host: db-2
+    port: 5433
+upstreams:
+  - name: api
+    host: api.internal
+    weight: 1
+    enabled: true
+  - name: web
+    host: web.internal
+dbs:
+  main:
+    host: main.internal
+    port: 5432
+labels:
+  team: core
+  tier: backend
+limits:
+  cpu: 2
+  mem: 512
+tls:
+  cert: base.pem
+  key: base.key
+`)
+	mustWrite(t, override, `origins = ["c"]
+plugins = ["tracing"]
+ports = [{ port = 6432 }]
+limits = { cpu = 4 }
+
+[[upstreams]]
+name = "api"
+weight = 5
+
+[[upstreams]]
+name = "batch"
+host = "batch.internal"
+
+[dbs.main]
+port = 6543
+
+[dbs.reporting]
+host = "reporting.internal"
+
+[labels]
+tier = "frontend"
+
+[tls]
+cert = "override.pem"
+`)
+
+	var c mergeConfig
+	var report Provenance
+	if err := Load(&c, WithFiles(base, override), WithProvenance(&report)); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if !reflect.DeepEqual(c.Origins, []string{"c"}) {
+		t.Fatalf("expected slices replaced by default, got %v", c.Origins)
+	}
+	if !reflect.DeepEqual(c.Plugins, []string{"auth", "metrics", "tracing"}) {
+		t.Fatalf("expected appended plugins, got %v", c.Plugins)
+	}
+	if !reflect.DeepEqual(c.Ports, []mergeDB{{Host: "db-1", Port: 6432}, {Host: "db-2", Port: 5433}}) {
+		t.Fatalf("expected ports merged by index, got %+v", c.Ports)
+	}
+
+	wantUpstreams := []mergeUpstream{
+		{Name: "api", Host: "api.internal", Weight: 5, Enabled: true},
+		{Name: "web", Host: "web.internal"},
+		{Name: "batch", Host: "batch.internal"},
+	}
+	if len(c.Upstreams) != len(wantUpstreams) {
+		t.Fatalf("expected %d upstreams, got %d", len(wantUpstreams), len(c.Upstreams))
+	}
+	for i, want := range wantUpstreams {
+		if *c.Upstreams[i] != want {
+			t.Fatalf("upstream %d: expected %+v, got %+v", i, want, *c.Upstreams[i])
+		}
+	}
+
+	wantDBs := map[string]mergeDB{"main": {Host: "main.internal", Port: 6543}, "reporting": {Host: "reporting.internal"}}
+	if !reflect.DeepEqual(c.DBs, wantDBs) {
+		t.Fatalf("expected dbs merged deeply, got %+v", c.DBs)
+	}
+	if !reflect.DeepEqual(c.Labels, map[string]string{"team": "core", "tier": "frontend"}) {
+		t.Fatalf("expected map keys merged by default, got %v", c.Labels)
+	}
+	if !reflect.DeepEqual(c.Limits, map[string]int{"cpu": 4}) {
+		t.Fatalf("expected replaced limits, got %v", c.Limits)
+	}
+	if c.TLS.Cert != "override.pem" || c.TLS.Key != "" {
+		t.Fatalf("expected replaced tls, got %+v", c.TLS)
+	}
+
+	if got, _ := report.Lookup("Upstreams.2.Host"); got.Origin.Name != override {
+		t.Fatalf("expected appended upstream recorded at its index, got %v", got.Origin)
+	}
+	if got, _ := report.Lookup("Upstreams.1.Host"); got.Origin.Name != base {
+		t.Fatalf("expected untouched upstream kept from the base file, got %v", got.Origin)
+	}
+}
+
+func TestLoadMergeTagErrors(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.json")
+	mustWrite(t, file, `{"Items":["a"],"Labels":{"a":"b"},"Hosts":[{"Host":"x"}]}`)
+
+	cases := map[string]struct {
+		config interface{}
+		want   string
+	}{
+		"unknown strategy": {&struct {
+			Items []string `merge:"union"`
+		}{}, `Items: unknown merge strategy "union"`},
+		"key on map": {&struct {
+			Labels map[string]string `merge:"key=a"`
+		}{}, "Labels: keyed merge needs a slice of structs"},
+		"key on scalars": {&struct {
+			Items []string `merge:"key=a"`
+		}{}, "Items: keyed merge needs a slice of structs"},
+		"missing key field": {&struct {
+			Hosts []struct{ Host string } `merge:"key=ID"`
+		}{}, `Hosts: keyed merge: no field "ID"`},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := Load(tc.config, WithSources(FileSource{Path: file}))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,378 @@
+package konfig
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OriginKind classifies the source that supplied a field.
+type OriginKind string
+
+const (
+	// OriginDefault marks values seeded from `default` struct tags.
+	OriginDefault OriginKind = "default"
+	// OriginFile marks values decoded from a configuration file.
+	OriginFile OriginKind = "file"
+	// OriginEnv marks values read from an environment variable.
+	OriginEnv OriginKind = "env"
+	// OriginSource marks values a custom Source supplied through Layer.Merge
+	// or Layer.Set.
+	OriginSource OriginKind = "source"
+)
+
+// Origin identifies a single source that supplied a field.
+type Origin struct {
+	Kind OriginKind
+	// Name is the file path for file origins, the variable name for env
+	// origins and the Source name for custom sources. It is empty for
+	// defaults.
+	Name string
+	// Line is the 1-based line of the key in the file, or 0 when unknown.
+	Line int
+	// Unset marks a source that cleared the field to its zero value, with a
+	// null in a file or an _UNSET environment variable.
+	Unset bool
+}
+
+func (o Origin) String() string {
+	if o.Unset {
+		cleared := o
+		cleared.Unset = false
+		return cleared.String() + " (unset)"
+	}
+
+	switch o.Kind {
+	case OriginFile:
+		if o.Line > 0 {
+			return o.Name + ":" + strconv.Itoa(o.Line)
+		}
+		return o.Name
+	case OriginEnv:
+		return "env " + o.Name
+	case OriginSource:
+		return o.Name
+	}
+	return string(o.Kind)
+}
+
+// FieldProvenance records where a field's final value came from.
+type FieldProvenance struct {
+	// Path is the dotted Go field path, e.g. "Database.Port".
+	Path string
+	// Origin is the source whose value won.
+	Origin Origin
+	// Overridden lists the sources that supplied the field earlier and were
+	// overridden, most recent first.
+	Overridden []Origin
+}
+
+// Provenance is the report filled in by WithProvenance.
+type Provenance struct {
+	// Fields is keyed by dotted Go field path. Slice elements and map entries
+	// appear under their index or key, e.g. "Upstreams.0.Host".
+	Fields map[string]FieldProvenance
+}
+
+// Lookup returns the provenance of path. Values that were only supplied as a
+// whole, such as the entries of a map decoded from a file, report the
+// provenance of the closest enclosing field that was recorded.
+func (p *Provenance) Lookup(path string) (FieldProvenance, bool) {
+	for {
+		if field, ok := p.Fields[path]; ok {
+			return field, true
+		}
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			return FieldProvenance{}, false
+		}
+		path = path[:i]
+	}
+}
+
+// String lists every recorded field in path order, one per line.
+func (p *Provenance) String() string {
+	paths := make([]string, 0, len(p.Fields))
+	for path := range p.Fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	for _, path := range paths {
+		field := p.Fields[path]
+		fmt.Fprintf(&b, "%s: %s", path, field.Origin)
+		if len(field.Overridden) > 0 {
+			overridden := make([]string, len(field.Overridden))
+			for i, origin := range field.Overridden {
+				overridden[i] = origin.String()
+			}
+			fmt.Fprintf(&b, " (overrides %s)", strings.Join(overridden, ", "))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func (o *origins) provenance() Provenance {
+	report := Provenance{Fields: make(map[string]FieldProvenance, len(o.fields))}
+	for path, chain := range o.fields {
+		field := FieldProvenance{Path: path, Origin: chain[len(chain)-1]}
+		for i := len(chain) - 2; i >= 0; i-- {
+			field.Overridden = append(field.Overridden, chain[i])
+		}
+		report.Fields[path] = field
+	}
+	return report
+}
+
+// keyLines maps the dotted key paths of a configuration file to the line each
+// key appears on. List items are addressed by index. It is a best-effort
+// scan: keys it cannot place are simply absent.
+func keyLines(format string, data []byte) map[string]int {
+	lines := map[string]int{}
+	switch format {
+	case formatJSON:
+		jsonKeyLines(data, lines)
+	case formatTOML:
+		tomlKeyLines(data, lines)
+	case formatYAML:
+		yamlKeyLines(data, lines)
+	}
+	return lines
+}
+
+func jsonKeyLines(data []byte, lines map[string]int) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	var walk func(path string) bool
+	walk = func(path string) bool {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+
+		switch token {
+		case json.Delim('{'):
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return false
+				}
+				child := joinPath(path, fmt.Sprint(key))
+				lines[child] = lineAt(data, int(decoder.InputOffset()))
+				if !walk(child) {
+					return false
+				}
+			}
+		case json.Delim('['):
+			for i := 0; decoder.More(); i++ {
+				child := joinPath(path, strconv.Itoa(i))
+				lines[child] = lineAt(data, nextValue(data, int(decoder.InputOffset())))
+				if !walk(child) {
+					return false
+				}
+			}
+		default:
+			return true
+		}
+
+		_, err = decoder.Token()
+		return err == nil
+	}
+
+	walk("")
+}
+
+// nextValue skips the whitespace and separator that precede a JSON array
+// element starting at offset.
+func nextValue(data []byte, offset int) int {
+	for offset < len(data) && strings.IndexByte(" \t\r\n,", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+func lineAt(data []byte, offset int) int {
+	return bytes.Count(data[:min(offset, len(data))], []byte("\n")) + 1
+}
+
+func tomlKeyLines(data []byte, lines map[string]int) {
+	table := ""
+	arrays := map[string]int{}
+	inString := false
+
+	for i, raw := range strings.Split(string(data), "\n") {
+		lineNo := i + 1
+		line := strings.TrimSpace(raw)
+
+		// Skip the bodies of multi-line strings so their content is not
+		// mistaken for keys.
+		if n := strings.Count(line, `"""`) + strings.Count(line, `'''`); inString || n > 0 {
+			wasInString := inString
+			inString = inString != (n%2 == 1)
+			if wasInString {
+				continue
+			}
+		}
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "[["):
+			parts := splitTOMLKey(strings.TrimPrefix(strings.SplitN(line, "]]", 2)[0], "[["))
+			name := joinPath(tomlTablePath(parts[:len(parts)-1], arrays), parts[len(parts)-1])
+			lines[name] = cmp.Or(lines[name], lineNo)
+			table = joinPath(name, strconv.Itoa(arrays[name]))
+			arrays[name]++
+			lines[table] = lineNo
+		case strings.HasPrefix(line, "["):
+			table = tomlTablePath(splitTOMLKey(strings.TrimPrefix(strings.SplitN(line, "]", 2)[0], "[")), arrays)
+			lines[table] = lineNo
+		default:
+			key, _, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			path := table
+			for _, part := range splitTOMLKey(key) {
+				path = joinPath(path, part)
+				lines[path] = cmp.Or(lines[path], lineNo)
+			}
+			lines[path] = lineNo
+		}
+	}
+}
+
+// tomlTablePath resolves the parts of a table header to a key path, inserting
+// the current index of any array of tables it is nested in.
+func tomlTablePath(parts []string, arrays map[string]int) string {
+	path := ""
+	for _, part := range parts {
+		path = joinPath(path, part)
+		if count, ok := arrays[path]; ok {
+			path = joinPath(path, strconv.Itoa(count-1))
+		}
+	}
+	return path
+}
+
+// splitTOMLKey splits a dotted TOML key, honouring quoted segments.
+func splitTOMLKey(key string) []string {
+	var parts []string
+	var current strings.Builder
+	var quote rune
+
+	for _, r := range strings.TrimSpace(key) {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '.':
+			parts = append(parts, strings.TrimSpace(current.String()))
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(parts, strings.TrimSpace(current.String()))
+}
+
+func yamlKeyLines(data []byte, lines map[string]int) {
+	type frame struct {
+		indent int
+		path   string
+		// key frames are opened by a mapping key; a sequence may continue at
+		// the same indentation as its key.
+		key   bool
+		items int
+	}
+
+	stack := []*frame{{indent: -1}}
+	blockIndent := -1
+
+	for i, raw := range strings.Split(string(data), "\n") {
+		lineNo := i + 1
+		content := strings.TrimLeft(raw, " ")
+		indent := len(raw) - len(content)
+		content = strings.TrimSpace(content)
+
+		if content == "" || strings.HasPrefix(content, "#") {
+			continue
+		}
+		if blockIndent >= 0 {
+			if indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+		if content == "---" || content == "..." {
+			stack = stack[:1]
+			continue
+		}
+
+		for len(stack) > 1 {
+			top := stack[len(stack)-1]
+			if indent > top.indent || (indent == top.indent && top.key && strings.HasPrefix(content, "-")) {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+
+		for content == "-" || strings.HasPrefix(content, "- ") {
+			parent := stack[len(stack)-1]
+			item := &frame{indent: indent, path: joinPath(parent.path, strconv.Itoa(parent.items))}
+			parent.items++
+			lines[item.path] = lineNo
+			stack = append(stack, item)
+
+			rest := strings.TrimLeft(strings.TrimPrefix(content, "-"), " ")
+			indent += len(content) - len(rest)
+			content = rest
+		}
+
+		key, value, ok := yamlKey(content)
+		if !ok {
+			continue
+		}
+		parent := stack[len(stack)-1]
+		path := joinPath(parent.path, key)
+		lines[path] = lineNo
+		stack = append(stack, &frame{indent: indent, path: path, key: true})
+
+		if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+			blockIndent = indent
+		}
+	}
+}
+
+// yamlKey splits a block mapping line into its key and the rest of the line.
+func yamlKey(content string) (string, string, bool) {
+	if content == "" || strings.IndexByte("[{&*!|>", content[0]) >= 0 {
+		return "", "", false
+	}
+
+	if quote := content[0]; quote == '"' || quote == '\'' {
+		end := strings.IndexByte(content[1:], quote)
+		if end < 0 || !strings.HasPrefix(content[end+2:], ":") {
+			return "", "", false
+		}
+		return content[1 : end+1], strings.TrimSpace(content[end+3:]), true
+	}
+
+	for i := 0; i < len(content); i++ {
+		if content[i] == ':' && (i+1 == len(content) || content[i+1] == ' ') {
+			return strings.TrimSpace(content[:i]), strings.TrimSpace(content[i+1:]), true
+		}
+		if content[i] == '#' && i > 0 && content[i-1] == ' ' {
+			break
+		}
+	}
+	return "", "", false
+}
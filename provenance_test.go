@@ -0,0 +1,125 @@
+package konfig
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type provenanceConfig struct {
+	Name     string `default:"svc"`
+	Database struct {
+		Host string
+		Port int `default:"5432"`
+	}
+	Labels map[string]string
+}
+
+func TestWithProvenanceRecordsChain(t *testing.T) {
+	dir := t.TempDir()
+	app := filepath.Join(dir, "app.yaml")
+	override := filepath.Join(dir, "override.toml")
+	mustWrite(t, app, "Database:\n  Host: db.local\n  Port: 5433\nLabels:\n  tier: web\n")
+	mustWrite(t, override, "# production\n[Database]\nPort = 6543\n")
+
+	t.Setenv("APP_DATABASE_PORT", "7000")
+
+	var (
+		c      provenanceConfig
+		report Provenance
+	)
+	if err := Load(&c, WithFiles(app, override), WithEnvPrefix("APP"), WithProvenance(&report)); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	port, ok := report.Lookup("Database.Port")
+	if !ok {
+		t.Fatalf("expected Database.Port in report, got %v", report.Fields)
+	}
+	if port.Origin != (Origin{Kind: OriginEnv, Name: "APP_DATABASE_PORT"}) {
+		t.Fatalf("unexpected winning origin %+v", port.Origin)
+	}
+	wantOverridden := []Origin{
+		{Kind: OriginFile, Name: override, Line: 3},
+		{Kind: OriginFile, Name: app, Line: 3},
+		{Kind: OriginDefault},
+	}
+	if !reflect.DeepEqual(port.Overridden, wantOverridden) {
+		t.Fatalf("unexpected overridden chain %+v", port.Overridden)
+	}
+
+	if host, _ := report.Lookup("Database.Host"); host.Origin.String() != app+":2" {
+		t.Fatalf("expected host from %s:2, got %s", app, host.Origin)
+	}
+	if name, _ := report.Lookup("Name"); name.Origin.Kind != OriginDefault {
+		t.Fatalf("expected name from default, got %+v", name)
+	}
+
+	tier, ok := report.Lookup("Labels.tier")
+	if !ok || tier.Path != "Labels" || tier.Origin.Line != 4 {
+		t.Fatalf("expected map entry to fall back to its field, got %+v", tier)
+	}
+	if _, ok := report.Lookup("Missing"); ok {
+		t.Fatalf("expected unknown path to be absent")
+	}
+
+	want := "Database.Port: env APP_DATABASE_PORT (overrides " + override + ":3, " + app + ":3, default)\n"
+	if !strings.Contains(report.String(), want) {
+		t.Fatalf("expected report to contain %q, got:\n%s", want, report.String())
+	}
+}
+
+func TestWithProvenanceLineInViolations(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.json")
+	mustWrite(t, file, "{\n  \"Workers\": 0\n}\n")
+
+	var c struct {
+		Workers int `validate:"min=1"`
+	}
+	var report Provenance
+	err := Load(&c, WithFiles(file), WithProvenance(&report))
+	if err == nil || !strings.Contains(err.Error(), "from "+file+":2") {
+		t.Fatalf("expected violation to name the line, got %v", err)
+	}
+	if len(report.Fields) != 1 {
+		t.Fatalf("expected report filled before validation, got %v", report.Fields)
+	}
+
+	err = Load(&c, WithFiles(file))
+	if err == nil || !strings.Contains(err.Error(), "from "+file+")") {
+		t.Fatalf("expected plain file source without provenance, got %v", err)
+	}
+}
+
+func TestKeyLines(t *testing.T) {
+	cases := []struct {
+		format string
+		data   string
+		want   map[string]int
+	}{
+		{
+			format: formatJSON,
+			data:   "{\n  \"server\": {\n    \"port\": 80\n  },\n  \"hosts\": [\n    {\"name\": \"a\"},\n    {\"name\": \"b\"}\n  ]\n}\n",
+			want:   map[string]int{"server": 2, "server.port": 3, "hosts": 5, "hosts.0": 6, "hosts.0.name": 6, "hosts.1": 7, "hosts.1.name": 7},
+		},
+		{
+			format: formatYAML,
+			data:   "server:\n  port: 80 # http\n  motd: |\n    key: not a key\n\"quoted key\": 1\nhosts:\n- name: a\n  port: 1\n-   name: b\n",
+			want:   map[string]int{"server": 1, "server.port": 2, "server.motd": 3, "quoted key": 5, "hosts": 6, "hosts.0": 7, "hosts.0.name": 7, "hosts.0.port": 8, "hosts.1": 9, "hosts.1.name": 9},
+		},
+		{
+			format: formatTOML,
+			data:   "title = \"x\"\nnotes = \"\"\"\nfake = 1\n\"\"\"\n[server]\nhttp.port = 80\n[[hosts]]\nname = \"a\"\n[[hosts]]\nname = \"b\"\n[hosts.tls]\ncert = \"c\"\n",
+			want:   map[string]int{"title": 1, "notes": 2, "server": 5, "server.http": 6, "server.http.port": 6, "hosts": 7, "hosts.0": 7, "hosts.0.name": 8, "hosts.1": 9, "hosts.1.name": 10, "hosts.1.tls": 11, "hosts.1.tls.cert": 12},
+		},
+	}
+
+	for _, tc := range cases {
+		got := keyLines(tc.format, []byte(tc.data))
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: unexpected lines\n got %v\nwant %v", tc.format, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package konfig
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pendingRef is a file value that refers to other keys and is expanded once
+// every source has been applied.
+type pendingRef struct {
+	// key is the dotted key path of the value in its file.
+	key    string
+	origin Origin
+}
+
+// resolveReferences expands the pending values of structValue that no later
+// source replaced, resolving the keys they refer to first.
+func resolveReferences(structValue reflect.Value, pending map[string]pendingRef, seen *origins) error {
+	live := map[string]pendingRef{}
+	for path, ref := range pending {
+		if last, ok := seen.last(path); ok && last.Kind == ref.origin.Kind && last.Name == ref.origin.Name && !last.Unset {
+			live[path] = ref
+		}
+	}
+
+	paths := make([]string, 0, len(live))
+	for path := range live {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	r := references{root: structValue, pending: live, done: map[string]bool{}}
+	for _, path := range paths {
+		if err := r.resolve(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type references struct {
+	root    reflect.Value
+	pending map[string]pendingRef
+	done    map[string]bool
+	// stack holds the Go paths being resolved, outermost first.
+	stack []string
+}
+
+// resolve expands the pending value at path in place.
+func (r *references) resolve(path string) error {
+	if r.done[path] {
+		return nil
+	}
+	ref := r.pending[path]
+
+	r.stack = append(r.stack, path)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	parts := strings.Split(path, ".")
+	raw, err := valueAtPath(r.root, parts)
+	if err == nil {
+		var expanded string
+		expanded, err = expandString(formatReferenced(raw), r.lookup)
+		if err == nil {
+			err = setAtPath(r.root, parts, expanded)
+		}
+	}
+
+	var resolved *InterpolationError
+	if errors.As(err, &resolved) {
+		return err
+	}
+	if err != nil {
+		return &InterpolationError{File: ref.origin.Name, Key: ref.key, Line: ref.origin.Line, Err: err}
+	}
+
+	r.done[path] = true
+	return nil
+}
+
+// lookup returns the final value of the key a reference names, or the value
+// of the environment variable for names without a dot. Only keys the struct
+// cannot hold are errors.
+func (r *references) lookup(name string) (string, error) {
+	if !isKeyReference(name) {
+		return envResolver(name)
+	}
+
+	path, err := referencePath(r.root.Type(), name)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := r.pending[path]; ok && !r.done[path] {
+		for i, open := range r.stack {
+			if open != path {
+				continue
+			}
+			chain := make([]string, 0, len(r.stack)-i+1)
+			for _, p := range r.stack[i:] {
+				chain = append(chain, r.pending[p].key)
+			}
+			return "", fmt.Errorf("reference cycle %s -> %s", strings.Join(chain, " -> "), r.pending[path].key)
+		}
+		if err := r.resolve(path); err != nil {
+			return "", err
+		}
+	}
+
+	// A missing map entry, slice element or nil pointer is simply unset.
+	value, err := valueAtPath(r.root, strings.Split(path, "."))
+	if err != nil {
+		return "", nil
+	}
+	return formatReferenced(value), nil
+}
+
+// referencePath translates a reference written with file keys, such as
+// database.host or upstreams.0.host, to a dotted Go field path.
+func referencePath(structType reflect.Type, name string) (string, error) {
+	name = strings.TrimPrefix(name, ".")
+	parts := strings.Split(name, ".")
+
+	var path []string
+	t := structType
+	for i, part := range parts {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := lookupFileField(fileFields(t, formatJSON), part)
+			if !ok {
+				field, ok = lookupFileField(fileFields(t, formatTOML), part)
+			}
+			if !ok {
+				return "", fmt.Errorf("unknown key %s", name)
+			}
+			path = append(path, field.path)
+			t = field.field.Type
+		case reflect.Slice, reflect.Array:
+			if _, err := strconv.Atoi(part); err != nil {
+				return "", fmt.Errorf("unknown key %s", name)
+			}
+			path = append(path, part)
+			t = t.Elem()
+		case reflect.Map:
+			path = append(path, part)
+			t = t.Elem()
+		case reflect.Interface:
+			path = append(path, parts[i:]...)
+			return strings.Join(path, "."), nil
+		default:
+			return "", fmt.Errorf("unknown key %s", name)
+		}
+	}
+	return strings.Join(path, "."), nil
+}
+
+var errNoValue = errors.New("no value at path")
+
+// valueAtPath walks a dotted Go field path below v, through pointers,
+// interfaces, slice indices and map keys.
+func valueAtPath(v reflect.Value, parts []string) (reflect.Value, error) {
+	for _, part := range parts {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return reflect.Value{}, errNoValue
+			}
+			v = v.Elem()
+		}
+
+		switch v.Kind() {
+		case reflect.Struct:
+			field, ok := v.Type().FieldByName(part)
+			if !ok {
+				return reflect.Value{}, errNoValue
+			}
+			next, err := v.FieldByIndexErr(field.Index)
+			if err != nil {
+				return reflect.Value{}, errNoValue
+			}
+			v = next
+		case reflect.Slice, reflect.Array:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= v.Len() {
+				return reflect.Value{}, errNoValue
+			}
+			v = v.Index(index)
+		case reflect.Map:
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := assignMapKey(key, part); err != nil {
+				return reflect.Value{}, errNoValue
+			}
+			if v = v.MapIndex(key); !v.IsValid() {
+				return reflect.Value{}, errNoValue
+			}
+		default:
+			return reflect.Value{}, errNoValue
+		}
+	}
+	return v, nil
+}
+
+// setAtPath stores s at a dotted Go field path below v. Map entries on the
+// way are copied, updated and stored back.
+func setAtPath(v reflect.Value, parts []string, s string) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return errNoValue
+		}
+		v = v.Elem()
+	}
+
+	if len(parts) == 0 {
+		switch v.Kind() {
+		case reflect.String:
+			v.SetString(s)
+		case reflect.Interface:
+			v.Set(reflect.ValueOf(s))
+		default:
+			return fmt.Errorf("cannot use string as %s", v.Type())
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		field, ok := v.Type().FieldByName(parts[0])
+		if !ok {
+			return errNoValue
+		}
+		next, err := v.FieldByIndexErr(field.Index)
+		if err != nil {
+			return errNoValue
+		}
+		return setAtPath(next, parts[1:], s)
+	case reflect.Slice, reflect.Array:
+		index, err := strconv.Atoi(parts[0])
+		if err != nil || index < 0 || index >= v.Len() {
+			return errNoValue
+		}
+		return setAtPath(v.Index(index), parts[1:], s)
+	case reflect.Map:
+		key := reflect.New(v.Type().Key()).Elem()
+		if err := assignMapKey(key, parts[0]); err != nil {
+			return errNoValue
+		}
+		existing := v.MapIndex(key)
+		if !existing.IsValid() {
+			return errNoValue
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		elem.Set(existing)
+		if err := setAtPath(elem, parts[1:], s); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+	}
+	return errNoValue
+}
+
+// formatReferenced renders a referenced value the way it would be written
+// in an environment variable; nil pointers and interfaces render empty.
+func formatReferenced(v reflect.Value) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
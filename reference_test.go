@@ -0,0 +1,87 @@
+package konfig
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+type referenceConfig struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Banner   string `json:"banner"`
+	Database struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	} `json:"database"`
+	Replicas []struct {
+		DSN string `json:"dsn"`
+	} `json:"replicas"`
+	Labels map[string]string `json:"labels"`
+}
+
+func TestWithInterpolationResolvesKeyReferences(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "default.yaml")
+	override := filepath.Join(dir, "override.json")
+	mustWrite(t, base, `name: svc
+url: postgres://${database.host}:${database.port}/${.name}
+banner: "${labels.owner:-nobody} runs ${.name} in ${REGION:-eu}, $$ and all"
+database:
+  host: localhost
+  port: 5432
+replicas:
+  - dsn: ${.url}?replica=1
+labels:
+  team: core
+  owner: ${labels.team}-team
+`)
+	mustWrite(t, override, `{"database":{"port":6432}}`)
+	t.Setenv("APP_DATABASE_HOST", "db.internal")
+	t.Setenv("REGION", "")
+
+	var c referenceConfig
+	if err := Load(&c, WithFiles(base, override), WithEnvPrefix("APP"), WithInterpolation()); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if c.URL != "postgres://db.internal:6432/svc" {
+		t.Fatalf("expected references resolved after every layer, got %q", c.URL)
+	}
+	if c.Replicas[0].DSN != "postgres://db.internal:6432/svc?replica=1" {
+		t.Fatalf("expected chained references resolved, got %q", c.Replicas[0].DSN)
+	}
+	if c.Labels["owner"] != "core-team" || c.Banner != "core-team runs svc in eu, $ and all" {
+		t.Fatalf("unexpected expansion %q %q", c.Labels["owner"], c.Banner)
+	}
+
+	t.Setenv("APP_URL", "literal ${database.host}")
+	if err := Load(&c, WithFiles(base, override), WithEnvPrefix("APP"), WithInterpolation()); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.URL != "literal ${database.host}" || c.Replicas[0].DSN != "literal ${database.host}?replica=1" {
+		t.Fatalf("expected overridden value left unexpanded, got %q and %q", c.URL, c.Replicas[0].DSN)
+	}
+}
+
+func TestWithInterpolationKeyReferenceErrors(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.yaml")
+	cases := map[string]string{
+		"name: ${.url}\nurl: ${labels.x:-${.name}}\n": file + ":2: url: reference cycle name -> url -> name",
+		"name: svc\nurl: ${database.hots}\n":          file + ":2: url: unknown key database.hots",
+		"name: svc\nurl: ${.url}\n":                   file + ":2: url: reference cycle url -> url",
+		"name: svc\nurl: ${replicas.0.dsn:?no dsn}\n": file + ":2: url: replicas.0.dsn: no dsn",
+	}
+
+	for content, want := range cases {
+		mustWrite(t, file, content)
+
+		var c referenceConfig
+		err := Load(&c, WithFiles(file), WithInterpolation())
+		var interpolation *InterpolationError
+		if !errors.As(err, &interpolation) || err.Error() != "konfig: interpolate "+want {
+			t.Fatalf("unexpected error for %q:\n got %v\nwant konfig: interpolate %s", content, err, want)
+		}
+	}
+}
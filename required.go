@@ -0,0 +1,83 @@
+package konfig
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MissingField describes a required field that no source supplied.
+type MissingField struct {
+	// Path is the dotted Go field path, e.g. "Database.URL".
+	Path string
+	// EnvKey is the environment variable that would have satisfied the field,
+	// or empty when the field has no environment key.
+	EnvKey string
+}
+
+// MissingError reports every required field that no file or environment
+// variable supplied during Load.
+type MissingError struct {
+	Fields []MissingField
+}
+
+func (e *MissingError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		if field.EnvKey == "" {
+			parts = append(parts, field.Path)
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s (env %s)", field.Path, field.EnvKey))
+	}
+	return "konfig: missing required configuration: " + strings.Join(parts, ", ")
+}
+
+// checkRequired returns a MissingError listing each field marked `required`
+// in its env or konfig tag that seen has no source for. Environment keys are
+// derived from prefix and only reported when withEnv is set.
+func checkRequired(structValue reflect.Value, prefix string, withEnv bool, seen *origins) error {
+	var missing []MissingField
+	collectMissing(structValue.Type(), prefix, "", seen, &missing)
+
+	if len(missing) == 0 {
+		return nil
+	}
+	if !withEnv {
+		for i := range missing {
+			missing[i].EnvKey = ""
+		}
+	}
+	return &MissingError{Fields: missing}
+}
+
+func collectMissing(structType reflect.Type, prefix, path string, seen *origins, missing *[]MissingField) {
+	for i := 0; i < structType.NumField(); i++ {
+		fieldType := structType.Field(i)
+		if !fieldType.IsExported() {
+			continue
+		}
+
+		fieldPath := joinPath(path, fieldType.Name)
+		key, ok := envKey(fieldType, prefix)
+		if !ok {
+			key = ""
+		}
+
+		if hasTagOption(fieldType, "required") && !seen.suppliedUnder(fieldPath) {
+			*missing = append(*missing, MissingField{Path: fieldPath, EnvKey: key})
+			continue
+		}
+
+		switch {
+		case fieldType.Type.Kind() == reflect.Struct && !isLeafStruct(fieldType.Type):
+			collectMissing(fieldType.Type, key, fieldPath, seen, missing)
+		case fieldType.Type.Kind() == reflect.Ptr && fieldType.Type.Elem().Kind() == reflect.Struct && !isLeafStruct(fieldType.Type.Elem()):
+			// Optional nested sections only enforce their own requirements
+			// once some source has supplied part of them.
+			if seen.suppliedUnder(fieldPath) {
+				collectMissing(fieldType.Type.Elem(), key, fieldPath, seen, missing)
+			}
+		}
+	}
+}
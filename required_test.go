@@ -0,0 +1,142 @@
+package konfig
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadRequiredReportsAllMissing(t *testing.T) {
+	type database struct {
+		URL  string `env:"URL,required"`
+		Pool int
+	}
+
+	type cfg struct {
+		Server   string `konfig:",required"`
+		Port     int    `konfig:"listen_port,required"`
+		Debug    bool
+		Database database
+	}
+
+	t.Setenv("APP_DEBUG", "true")
+
+	var c cfg
+	err := Load(&c, WithEnvPrefix("APP"))
+
+	var missing *MissingError
+	if !errors.As(err, &missing) {
+		t.Fatalf("expected MissingError, got %v", err)
+	}
+
+	want := []MissingField{
+		{Path: "Server", EnvKey: "APP_SERVER"},
+		{Path: "Port", EnvKey: "APP_LISTEN_PORT"},
+		{Path: "Database.URL", EnvKey: "APP_DATABASE_URL"},
+	}
+	if len(missing.Fields) != len(want) {
+		t.Fatalf("expected %d missing fields, got %#v", len(want), missing.Fields)
+	}
+	for i, field := range want {
+		if missing.Fields[i] != field {
+			t.Fatalf("missing field %d: expected %+v, got %+v", i, field, missing.Fields[i])
+		}
+	}
+
+	if !strings.Contains(err.Error(), "Database.URL (env APP_DATABASE_URL)") {
+		t.Fatalf("expected error to name path and env key, got %v", err)
+	}
+}
+
+func TestLoadRequiredSatisfiedByFileAndEnv(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+	mustWrite(t, file, "server = \"from-file\"\n\n[database]\nurl = \"postgres://db\"\n")
+
+	type cfg struct {
+		Server   string `konfig:",required"`
+		Port     int    `env:"PORT,required"`
+		Database struct {
+			URL string `toml:"url" env:",required"`
+		}
+	}
+
+	t.Setenv("APP_PORT", "8080")
+
+	var c cfg
+	if err := Load(&c, WithFiles(file), WithEnvPrefix("APP")); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if c.Server != "from-file" || c.Port != 8080 || c.Database.URL != "postgres://db" {
+		t.Fatalf("unexpected config: %+v", c)
+	}
+}
+
+func TestLoadRequiredIgnoresDefaults(t *testing.T) {
+	type cfg struct {
+		Name string `default:"fallback" konfig:",required"`
+	}
+
+	var c cfg
+	err := Load(&c, WithDefaultsAsSource())
+
+	var missing *MissingError
+	if !errors.As(err, &missing) || missing.Fields[0].Path != "Name" {
+		t.Fatalf("expected Name to be reported missing, got %v", err)
+	}
+}
+
+func TestLoadRequiredOptionalPointerSection(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.json")
+	mustWrite(t, file, `{"Name":"svc"}`)
+
+	type tls struct {
+		Cert string `konfig:",required"`
+		Key  string `konfig:",required"`
+	}
+
+	type cfg struct {
+		Name string
+		TLS  *tls
+	}
+
+	var c cfg
+	if err := Load(&c, WithFiles(file)); err != nil {
+		t.Fatalf("expected absent optional section to be skipped, got %v", err)
+	}
+
+	mustWrite(t, file, `{"Name":"svc","TLS":{"Cert":"cert.pem"}}`)
+
+	var partial cfg
+	err := Load(&partial, WithFiles(file))
+
+	var missing *MissingError
+	if !errors.As(err, &missing) || len(missing.Fields) != 1 || missing.Fields[0].Path != "TLS.Key" {
+		t.Fatalf("expected TLS.Key missing, got %v", err)
+	}
+}
+
+func TestLoadRequiredEmbeddedFields(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	mustWrite(t, file, "host: example.com\n")
+
+	type Base struct {
+		Host string `json:"host" konfig:",required"`
+	}
+
+	type cfg struct {
+		Base
+	}
+
+	var c cfg
+	if err := Load(&c, WithFiles(file)); err != nil {
+		t.Fatalf("expected promoted field to satisfy requirement, got %v", err)
+	}
+	if c.Host != "example.com" {
+		t.Fatalf("expected host from file, got %q", c.Host)
+	}
+}
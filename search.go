@@ -0,0 +1,113 @@
+package konfig
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// WithSearchPaths looks for the WithBase file, and its WithProfile files, in
+// each of dirs instead of relative to the working directory. Dirs are listed
+// from highest to lowest precedence, the way DefaultSearchPaths returns them;
+// empty entries are skipped and an absolute base is used as is. By default
+// the first directory holding the file wins; see WithMergedSearch.
+func WithSearchPaths(dirs ...string) Option {
+	return func(o *options) {
+		o.searchPaths = append(o.searchPaths, dirs...)
+		o.searchSet = true
+	}
+}
+
+// WithMergedSearch makes Load apply the file from every search path that
+// holds one instead of only the first, starting with the last directory, so
+// system-wide files come first and user files override them.
+func WithMergedSearch() Option {
+	return func(o *options) {
+		o.mergedSearch = true
+	}
+}
+
+// DefaultSearchPaths returns the conventional directories for app, from
+// highest to lowest precedence: the directory of the running executable, the
+// user configuration directory, each $XDG_CONFIG_DIRS entry and /etc/<app>.
+// Directories that cannot be determined are left out.
+func DefaultSearchPaths(app string) []string {
+	var dirs []string
+	for _, dir := range []string{ExecutableDir(), UserConfigDir(app)} {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return append(dirs, SystemConfigDirs(app)...)
+}
+
+// ExecutableDir returns the directory of the running executable with
+// symlinks resolved, or "" when it cannot be determined.
+func ExecutableDir() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return filepath.Dir(exe)
+}
+
+// UserConfigDir returns the per-user configuration directory for app,
+// $XDG_CONFIG_HOME/<app> or ~/.config/<app> on Unix, or "" when there is no
+// home directory.
+func UserConfigDir(app string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, app)
+}
+
+// SystemConfigDirs returns the system-wide configuration directories for
+// app: <dir>/<app> for each entry of $XDG_CONFIG_DIRS, or /etc/xdg/<app> when
+// it is unset, followed by /etc/<app>.
+func SystemConfigDirs(app string) []string {
+	xdg := os.Getenv("XDG_CONFIG_DIRS")
+	if xdg == "" {
+		xdg = "/etc/xdg"
+	}
+
+	var dirs []string
+	for _, dir := range filepath.SplitList(xdg) {
+		if filepath.IsAbs(dir) {
+			dirs = append(dirs, filepath.Join(dir, app))
+		}
+	}
+	return append(dirs, filepath.Join("/etc", app))
+}
+
+// searchSources returns the sources resolving stem, a base or profile file
+// name without extension, across the search paths.
+func (o options) searchSources(stem string, extra []string) []Source {
+	if !o.searchSet || filepath.IsAbs(stem) {
+		return []Source{FirstOfSource{Paths: baseFiles(stem, extra)}}
+	}
+
+	var dirs []string
+	for _, dir := range o.searchPaths {
+		if dir != "" && !slices.Contains(dirs, filepath.Clean(dir)) {
+			dirs = append(dirs, filepath.Clean(dir))
+		}
+	}
+
+	if !o.mergedSearch {
+		var paths []string
+		for _, dir := range dirs {
+			paths = append(paths, baseFiles(filepath.Join(dir, stem), extra)...)
+		}
+		return []Source{FirstOfSource{Paths: paths}}
+	}
+
+	sources := make([]Source, 0, len(dirs))
+	for i := len(dirs) - 1; i >= 0; i-- {
+		sources = append(sources, FirstOfSource{Paths: baseFiles(filepath.Join(dirs[i], stem), extra)})
+	}
+	return sources
+}
@@ -0,0 +1,63 @@
+package konfig
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWithSearchPaths(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user")
+	system := filepath.Join(dir, "etc")
+	mustWrite(t, filepath.Join(system, "app.yaml"), "name: system\nport: 80\ndatabase:\n  host: db\n")
+	mustWrite(t, filepath.Join(system, "app.production.yaml"), "database:\n  port: 5432\n")
+	mustWrite(t, filepath.Join(user, "app.toml"), "Port = 8080\n")
+	mustWrite(t, filepath.Join(user, "app.production.json"), `{"database":{"host":"db.user"}}`)
+
+	var c sourceConfig
+	if err := Load(&c, WithBase("app"), WithSearchPaths(filepath.Join(dir, "missing"), user, "", system)); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Name != "" || c.Port != 8080 {
+		t.Fatalf("expected only the first match applied, got %+v", c)
+	}
+
+	var (
+		merged sourceConfig
+		report Provenance
+	)
+	err := Load(&merged, WithBase("app"), WithProfile("production"), WithSearchPaths(user, system), WithMergedSearch(), WithProvenance(&report))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if merged.Name != "system" || merged.Port != 8080 || merged.Database.Host != "db.user" || merged.Database.Port != 5432 {
+		t.Fatalf("expected system files then user files, got %+v", merged)
+	}
+	if p, _ := report.Lookup("Port"); p.Origin.Name != filepath.Join(user, "app.toml") || p.Overridden[0].Name != filepath.Join(system, "app.yaml") {
+		t.Fatalf("unexpected provenance %+v", p)
+	}
+
+	if err := Load(&c, WithSearchPaths(user)); err == nil || !strings.Contains(err.Error(), "konfig: WithSearchPaths requires WithBase") {
+		t.Fatalf("expected missing base error, got %v", err)
+	}
+}
+
+func TestDefaultSearchPaths(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/home/u/.config")
+	t.Setenv("XDG_CONFIG_DIRS", "/opt/xdg:relative:/etc/xdg")
+
+	want := []string{ExecutableDir(), "/home/u/.config/app", "/opt/xdg/app", "/etc/xdg/app", "/etc/app"}
+	if got := DefaultSearchPaths("app"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected search paths:\n got %v\nwant %v", got, want)
+	}
+	if ExecutableDir() == "" {
+		t.Fatalf("expected the test binary's directory")
+	}
+
+	t.Setenv("XDG_CONFIG_DIRS", "")
+	if got := SystemConfigDirs("app"); !reflect.DeepEqual(got, []string{"/etc/xdg/app", "/etc/app"}) {
+		t.Fatalf("unexpected system dirs %v", got)
+	}
+}
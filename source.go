@@ -0,0 +1,337 @@
+package konfig
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Source supplies configuration values to Load. Sources are applied in order
+// on top of `default` tags and Defaulter hooks, so later sources override
+// earlier ones.
+type Source interface {
+	// Name identifies the source in errors and provenance reports.
+	Name() string
+	// Load writes the values the source supplies into layer. Errors are
+	// returned from Load unchanged.
+	Load(layer *Layer) error
+}
+
+// WithSources declares the exact, ordered chain of sources Load applies. It
+// replaces the chain otherwise built from WithBase, WithProfile,
+// WithSearchPaths, WithFiles and WithEnvPrefix, which cannot be combined with
+// it.
+func WithSources(sources ...Source) Option {
+	return func(o *options) {
+		o.sources = append(o.sources, sources...)
+	}
+}
+
+// Layer is the configuration being loaded, as seen by a Source.
+type Layer struct {
+	target   reflect.Value
+	seen     *origins
+	decoders map[string]Decoder
+	name     string
+	supplied bool
+	// strict collects unknown keys instead of ignoring them.
+	strict  bool
+	unknown []UnknownKey
+	// normalizeKeys matches keys ignoring case and separators.
+	normalizeKeys bool
+	// interpolate expands ${VAR} references in decoded file values.
+	interpolate bool
+	// pending holds, by Go field path, the values referring to other keys.
+	pending map[string]pendingRef
+	// includeRoot confines $include directives, instead of the directory of
+	// the including file.
+	includeRoot string
+	// onInclude, when set, is told the name of every included file read
+	// from the operating system.
+	onInclude func(name string)
+}
+
+// Decode parses data as a configuration file and merges it into the layer.
+// The decoder is chosen by the extension of name (.json, .toml, .yaml, .yml
+// or one given to RegisterDecoder or WithDecoder), and the format is detected
+// from the content otherwise; name is also what errors and provenance report
+// as the origin. Files named by an $include directive are read from the
+// operating system relative to name.
+func (l *Layer) Decode(name string, data []byte) error {
+	return l.decode(nil, nil, name, data)
+}
+
+// decode is Decode for a file read from fsys, or the operating system when
+// fsys is nil. inc is nil for a top-level file and carries the include root
+// and chain for included ones.
+func (l *Layer) decode(fsys fs.FS, inc *includer, name string, data []byte) error {
+	format, tree, err := decodeByExtension(name, data, l.decoders)
+	if err != nil {
+		return fmt.Errorf("konfig: decode %s: %w", name, err)
+	}
+
+	patterns, err := takeIncludes(tree)
+	if err != nil {
+		return fmt.Errorf("konfig: decode %s: %w", name, err)
+	}
+	if len(patterns) > 0 {
+		if inc == nil {
+			if inc, err = l.openIncluder(fsys, name); err != nil {
+				return err
+			}
+			defer inc.close()
+		}
+		if err := inc.include(l, patterns); err != nil {
+			return err
+		}
+	}
+
+	deferred := map[string]bool{}
+	if l.interpolate {
+		if key, err := interpolateTree(tree, "", deferred); err != nil {
+			return &InterpolationError{File: name, Key: key, Line: keyLines(format, data)[key], Err: err}
+		}
+	}
+
+	var lines map[string]int
+	if l.strict || len(deferred) > 0 || (l.seen != nil && l.seen.positions) {
+		lines = keyLines(format, data)
+	}
+
+	if err := l.bind(tree, format, Origin{Kind: OriginFile, Name: name}, lines, deferred, data); err != nil {
+		return fmt.Errorf("konfig: decode %s: %w", name, err)
+	}
+
+	l.supplied = true
+	return nil
+}
+
+// Merge binds an already decoded tree of values onto the layer. Keys are
+// matched to fields like JSON object keys, and values may be anything
+// encoding/json produces when decoding into interface{}, or any map, integer
+// and float types, normalized the same way as the output of a Decoder.
+func (l *Layer) Merge(tree map[string]interface{}) error {
+	if len(tree) == 0 {
+		return nil
+	}
+	normalized, err := normalizeValue(tree)
+	if err == nil {
+		tree, _ = normalized.(map[string]interface{})
+		err = l.bind(tree, formatJSON, Origin{Kind: OriginSource, Name: l.name}, nil, nil, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("konfig: source %s: %w", l.name, err)
+	}
+	l.supplied = true
+	return nil
+}
+
+// bind binds tree onto the layer. data is the document tree was decoded from,
+// if any, and orders keys that match the same field.
+func (l *Layer) bind(tree map[string]interface{}, format string, origin Origin, lines map[string]int, deferred map[string]bool, data []byte) error {
+	b := binder{
+		format:    format,
+		origin:    origin,
+		seen:      l.seen,
+		lines:     lines,
+		keys:      map[string]string{},
+		normalize: l.normalizeKeys,
+		data:      data,
+	}
+	if l.strict {
+		b.unknown = &l.unknown
+	}
+	if len(deferred) > 0 {
+		if l.pending == nil {
+			l.pending = map[string]pendingRef{}
+		}
+		b.deferred, b.pending = deferred, l.pending
+	}
+	return b.bindStruct(l.target, tree, "")
+}
+
+// Set assigns value to the field at the dotted Go field path, e.g.
+// "Database.Port", converting it the same way as an environment variable.
+func (l *Layer) Set(path, value string) error {
+	field, tag, err := fieldByPath(l.target, path)
+	if err == nil {
+		err = assignFromStringWithTag(field, value, tag)
+	}
+	if err != nil {
+		return fmt.Errorf("konfig: source %s: set %s: %w", l.name, path, err)
+	}
+
+	l.seen.record(path, Origin{Kind: OriginSource, Name: l.name})
+	l.supplied = true
+	return nil
+}
+
+// fieldByPath resolves a dotted Go field path below structValue, allocating
+// nil pointers to structs along the way.
+func fieldByPath(structValue reflect.Value, path string) (reflect.Value, reflect.StructTag, error) {
+	current := structValue
+	var tag reflect.StructTag
+
+	for _, name := range strings.Split(path, ".") {
+		if current.Kind() == reflect.Ptr {
+			if current.IsNil() {
+				current.Set(reflect.New(current.Type().Elem()))
+			}
+			current = current.Elem()
+		}
+		if current.Kind() != reflect.Struct {
+			return reflect.Value{}, "", fmt.Errorf("%s is not a struct", current.Type())
+		}
+
+		field, ok := current.Type().FieldByName(name)
+		if !ok || !field.IsExported() {
+			return reflect.Value{}, "", fmt.Errorf("no field %q in %s", name, current.Type())
+		}
+
+		value, err := fieldByIndexAlloc(current, field.Index)
+		if err != nil {
+			return reflect.Value{}, "", err
+		}
+		current, tag = value, field.Tag
+	}
+
+	return current, tag, nil
+}
+
+// FileSource reads a single configuration file. A missing file is skipped.
+type FileSource struct {
+	Path string
+	// FS, when set, is read instead of the operating system's file system,
+	// e.g. an embed.FS holding built-in defaults.
+	FS fs.FS
+}
+
+// Name returns the path of the file.
+func (s FileSource) Name() string { return s.Path }
+
+// Load decodes the file into layer, doing nothing when it does not exist.
+func (s FileSource) Load(layer *Layer) error {
+	_, err := loadSequential(s.FS, []string{s.Path}, layer)
+	return err
+}
+
+func (s FileSource) watchFiles() []string {
+	if s.FS != nil {
+		return nil
+	}
+	return []string{s.Path}
+}
+
+// FirstOfSource reads the first of Paths that exists and ignores the rest.
+// Load uses it to resolve WithBase.
+type FirstOfSource struct {
+	Paths []string
+	// FS, when set, is read instead of the operating system's file system.
+	FS fs.FS
+}
+
+// Name lists the candidate paths in order.
+func (s FirstOfSource) Name() string { return "first of " + strings.Join(s.Paths, ", ") }
+
+// Load decodes the first existing path into layer.
+func (s FirstOfSource) Load(layer *Layer) error {
+	_, err := loadFirstAvailable(s.FS, s.Paths, layer)
+	return err
+}
+
+func (s FirstOfSource) watchFiles() []string {
+	if s.FS != nil {
+		return nil
+	}
+	return s.Paths
+}
+
+// EnvSource reads environment variables, deriving each key from Prefix and
+// the field's tags as described for WithEnvPrefix.
+type EnvSource struct {
+	Prefix string
+}
+
+// Name returns "env".
+func (s EnvSource) Name() string { return "env" }
+
+// Load applies the environment variables that fields read under Prefix.
+func (s EnvSource) Load(layer *Layer) error {
+	applied, err := applyEnvOverrides(layer.target.Addr(), s.Prefix, layer.seen)
+	layer.supplied = layer.supplied || applied > 0
+	return err
+}
+
+// pipeline returns the sources Load applies after defaults, in order.
+func (o options) pipeline() ([]Source, error) {
+	if len(o.sources) > 0 {
+		if o.base != "" || len(o.files) > 0 || o.envPrefix != "" || o.profileSet || o.searchSet {
+			return nil, errors.New("konfig: WithSources cannot be combined with WithBase, WithProfile, WithSearchPaths, WithFiles or WithEnvPrefix")
+		}
+		return o.sources, nil
+	}
+
+	var sources []Source
+	if o.base != "" {
+		stems := []string{o.base}
+		if o.profileSet {
+			profile, err := o.activeProfile()
+			if err != nil {
+				return nil, err
+			}
+			if profile != "" {
+				stems = append(stems, o.base+"."+profile)
+			}
+			stems = append(stems, o.base+".local")
+		}
+
+		extra := extraExtensions(o.decoders)
+		for _, stem := range stems {
+			sources = append(sources, o.searchSources(stem, extra)...)
+		}
+	} else if o.profileSet {
+		return nil, errors.New("konfig: WithProfile requires WithBase")
+	} else if o.searchSet {
+		return nil, errors.New("konfig: WithSearchPaths requires WithBase")
+	}
+	for _, file := range o.files {
+		sources = append(sources, FileSource{Path: file})
+	}
+	return append(sources, EnvSource{Prefix: o.envPrefix}), nil
+}
+
+// profileKey is the environment variable WithProfile("") reads the profile
+// from.
+func (o options) profileKey() string {
+	if o.envPrefix == "" {
+		return "PROFILE"
+	}
+	return o.envPrefix + "_PROFILE"
+}
+
+// activeProfile returns the profile given to WithProfile, or read from the
+// environment when it was empty.
+func (o options) activeProfile() (string, error) {
+	profile := o.profile
+	if profile == "" {
+		profile = strings.TrimSpace(os.Getenv(o.profileKey()))
+	}
+	if profile == "." || profile == ".." || strings.ContainsAny(profile, `/\`) {
+		return "", fmt.Errorf("konfig: invalid profile %q", profile)
+	}
+	return profile, nil
+}
+
+// envPrefix returns the prefix of the last EnvSource in sources, reporting
+// whether there is one.
+func envPrefix(sources []Source) (string, bool) {
+	for i := len(sources) - 1; i >= 0; i-- {
+		if env, ok := sources[i].(EnvSource); ok {
+			return env.Prefix, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,221 @@
+package konfig
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+type sourceConfig struct {
+	Name     string
+	Port     int `konfig:",required"`
+	Database struct {
+		Host string
+		Port int
+	}
+	Hosts []string
+}
+
+// flagSource stands in for a third-party source that sets fields by path.
+type flagSource map[string]string
+
+func (flagSource) Name() string { return "flags" }
+
+func (f flagSource) Load(layer *Layer) error {
+	for _, path := range []string{"Name", "Database.Port"} {
+		if value, ok := f[path]; ok {
+			if err := layer.Set(path, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type remoteSource map[string]interface{}
+
+func (remoteSource) Name() string { return "remote" }
+
+func (r remoteSource) Load(layer *Layer) error { return layer.Merge(r) }
+
+func TestWithSourcesAppliesInOrder(t *testing.T) {
+	dir := t.TempDir()
+	system := filepath.Join(dir, "app.yaml")
+	mustWrite(t, system, "name: system\nport: 8080\ndatabase:\n  host: db.internal\n")
+
+	embedded := fstest.MapFS{
+		"defaults.toml": {Data: []byte("Name = \"embedded\"\nPort = 80\nHosts = [\"a\"]\n")},
+	}
+
+	t.Setenv("APP_DATABASE_HOST", "db.env")
+
+	var (
+		c      sourceConfig
+		report Provenance
+	)
+	err := Load(&c, WithProvenance(&report), WithSources(
+		FileSource{Path: "defaults.toml", FS: embedded},
+		FileSource{Path: system},
+		FileSource{Path: filepath.Join(dir, "missing.json")},
+		remoteSource{"Hosts": []interface{}{"b", "c"}},
+		EnvSource{Prefix: "APP"},
+		flagSource{"Database.Port": "6543"},
+	))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if c.Name != "system" || c.Port != 8080 {
+		t.Fatalf("expected system file over embedded defaults, got %+v", c)
+	}
+	if c.Database.Host != "db.env" || c.Database.Port != 6543 {
+		t.Fatalf("expected env then flags applied, got %+v", c.Database)
+	}
+	if strings.Join(c.Hosts, ",") != "b,c" {
+		t.Fatalf("expected merged hosts, got %v", c.Hosts)
+	}
+
+	if p, _ := report.Lookup("Database.Port"); p.Origin != (Origin{Kind: OriginSource, Name: "flags"}) {
+		t.Fatalf("unexpected origin %+v", p.Origin)
+	}
+	if p, _ := report.Lookup("Port"); p.Origin.Name != system || len(p.Overridden) != 1 || p.Overridden[0].Name != "defaults.toml" {
+		t.Fatalf("unexpected port provenance %+v", p)
+	}
+}
+
+// jsonSource merges what json.Unmarshal decodes, numbers as float64.
+type jsonSource string
+
+func (jsonSource) Name() string { return "js" }
+
+func (j jsonSource) Load(layer *Layer) error {
+	var tree map[string]interface{}
+	if err := json.Unmarshal([]byte(j), &tree); err != nil {
+		return err
+	}
+	return layer.Merge(tree)
+}
+
+func TestLayerMergeNumbers(t *testing.T) {
+	var c sourceConfig
+	err := Load(&c, WithSources(
+		jsonSource(`{"Port":8080,"Database":{"Port":5432}}`),
+		remoteSource{"Database": map[interface{}]interface{}{"Host": "db"}, "Hosts": []string{"a"}},
+	))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Port != 8080 || c.Database.Port != 5432 || c.Database.Host != "db" || c.Hosts[0] != "a" {
+		t.Fatalf("unexpected config %+v", c)
+	}
+
+	if err := Load(&c, WithSources(jsonSource(`{"Port":80.5}`))); err == nil || !strings.Contains(err.Error(), "konfig: source js: Port: strconv.ParseInt") {
+		t.Fatalf("expected fractional port rejected, got %v", err)
+	}
+}
+
+func TestWithSourcesErrors(t *testing.T) {
+	var c sourceConfig
+	if err := Load(&c, WithSources(EnvSource{}), WithEnvPrefix("APP")); err == nil || !strings.Contains(err.Error(), "cannot be combined") {
+		t.Fatalf("expected combination error, got %v", err)
+	}
+
+	err := Load(&c, WithSources(flagSource{"Name": "x"}, FirstOfSource{Paths: []string{"nope.json"}}))
+	var missing *MissingError
+	if !errors.As(err, &missing) || missing.Fields[0].EnvKey != "" {
+		t.Fatalf("expected missing error without env keys, got %v", err)
+	}
+
+	err = Load(&c, WithSources(flagSource{"Database.Port": "x"}))
+	if err == nil || !strings.Contains(err.Error(), "konfig: source flags: set Database.Port: strconv.ParseInt") {
+		t.Fatalf("expected set error, got %v", err)
+	}
+
+	err = Load(&c, WithSources(remoteSource{"Port": "x"}))
+	if err == nil || !strings.Contains(err.Error(), "konfig: source remote: Port: cannot use string as int") {
+		t.Fatalf("expected merge error, got %v", err)
+	}
+
+	if err := Load(&c, WithSources(remoteSource{})); !errors.Is(err, ErrNoSources) {
+		t.Fatalf("expected ErrNoSources, got %v", err)
+	}
+}
+
+func TestWithProfileLayersFiles(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app")
+	mustWrite(t, base+".yaml", "name: base\nport: 80\ndatabase:\n  host: db\n  port: 1\n")
+	mustWrite(t, base+".production.toml", "Port = 443\n\n[Database]\nHost = \"db.prod\"\n")
+	mustWrite(t, base+".staging.yaml", "port: 8443\n")
+	mustWrite(t, base+".local.json", `{"database":{"port":2}}`)
+	t.Setenv("APP_NAME", "env")
+
+	var (
+		c      sourceConfig
+		report Provenance
+	)
+	if err := Load(&c, WithBase(base), WithProfile("production"), WithEnvPrefix("APP"), WithProvenance(&report), WithStrayEnvErrors()); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Name != "env" || c.Port != 443 || c.Database.Host != "db.prod" || c.Database.Port != 2 {
+		t.Fatalf("expected base, profile, local then env, got %+v", c)
+	}
+	if p, _ := report.Lookup("Database.Port"); p.Origin.Name != base+".local.json" || p.Overridden[0].Name != base+".yaml" {
+		t.Fatalf("unexpected provenance %+v", p)
+	}
+
+	t.Setenv("APP_PROFILE", "staging")
+	c = sourceConfig{}
+	if err := Load(&c, WithBase(base), WithProfile(""), WithEnvPrefix("APP"), WithStrayEnvErrors()); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Port != 8443 || c.Database.Host != "db" {
+		t.Fatalf("expected profile from APP_PROFILE, got %+v", c)
+	}
+
+	t.Setenv("APP_PROFILE", "")
+	c = sourceConfig{}
+	if err := Load(&c, WithBase(base), WithProfile(""), WithEnvPrefix("APP")); err != nil || c.Port != 80 || c.Database.Port != 2 {
+		t.Fatalf("expected only base and local files without a profile, got %+v (%v)", c, err)
+	}
+}
+
+func TestWithProfileErrors(t *testing.T) {
+	var c sourceConfig
+	cases := map[string][]Option{
+		"konfig: WithProfile requires WithBase": {WithProfile("production")},
+		`konfig: invalid profile "../prod"`:     {WithBase("app"), WithProfile("../prod")},
+		"cannot be combined":                    {WithSources(EnvSource{}), WithProfile("production")},
+	}
+	for want, opts := range cases {
+		if err := Load(&c, opts...); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q, got %v", want, err)
+		}
+	}
+}
+
+func TestFieldByPath(t *testing.T) {
+	type inner struct{ Port int }
+	var c struct {
+		*inner
+		DB *struct{ Host string }
+	}
+
+	field, _, err := fieldByPath(reflect.ValueOf(&c).Elem(), "DB.Host")
+	if err != nil || !field.CanSet() || c.DB == nil {
+		t.Fatalf("expected pointer section allocated, got %v", err)
+	}
+	if _, _, err := fieldByPath(reflect.ValueOf(&c).Elem(), "DB.Missing"); err == nil || !strings.Contains(err.Error(), `no field "Missing"`) {
+		t.Fatalf("expected unknown field error, got %v", err)
+	}
+	if _, _, err := fieldByPath(reflect.ValueOf(&c).Elem(), "DB.Host.Len"); err == nil || !strings.Contains(err.Error(), "string is not a struct") {
+		t.Fatalf("expected non-struct error, got %v", err)
+	}
+	if _, _, err := fieldByPath(reflect.ValueOf(&c).Elem(), "Port"); err == nil || !strings.Contains(err.Error(), "unexported") {
+		t.Fatalf("expected unexported embedded pointer error, got %v", err)
+	}
+}
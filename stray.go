@@ -0,0 +1,166 @@
+package konfig
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// StrayEnv is an environment variable that starts with the env prefix but is
+// read by no field.
+type StrayEnv struct {
+	Key string
+	// Suggestion is the closest key that would have been read, or empty if
+	// none is close enough.
+	Suggestion string
+}
+
+func (s StrayEnv) String() string {
+	if s.Suggestion == "" {
+		return s.Key
+	}
+	return fmt.Sprintf("%s (did you mean %s?)", s.Key, s.Suggestion)
+}
+
+// StrayEnvError reports the stray variables found by WithStrayEnvErrors.
+type StrayEnvError struct {
+	Vars []StrayEnv
+}
+
+func (e *StrayEnvError) Error() string {
+	parts := make([]string, len(e.Vars))
+	for i, v := range e.Vars {
+		parts[i] = v.String()
+	}
+	return "konfig: unused environment variables: " + strings.Join(parts, ", ")
+}
+
+// WithStrayEnvWarnings calls warn for every environment variable that starts
+// with the prefix of an env source, followed by an underscore, but is read by
+// no field. Load carries on regardless. Sources without a prefix are not
+// checked.
+func WithStrayEnvWarnings(warn func(StrayEnv)) Option {
+	return func(o *options) {
+		o.strayEnvWarn = warn
+	}
+}
+
+// WithStrayEnvErrors is like WithStrayEnvWarnings but makes Load fail with a
+// *StrayEnvError instead.
+func WithStrayEnvErrors() Option {
+	return func(o *options) {
+		o.strayEnvErrors = true
+	}
+}
+
+// checkStrayEnv applies the stray variable policy of cfg to every EnvSource
+// in sources.
+func checkStrayEnv(structType reflect.Type, sources []Source, cfg options) error {
+	if cfg.strayEnvWarn == nil && !cfg.strayEnvErrors {
+		return nil
+	}
+
+	var stray []StrayEnv
+	for _, source := range sources {
+		if env, ok := source.(EnvSource); ok && env.Prefix != "" {
+			for _, v := range strayEnv(structType, env.Prefix) {
+				if cfg.profileSet && v.Key == cfg.profileKey() {
+					continue
+				}
+				stray = append(stray, v)
+			}
+		}
+	}
+
+	if cfg.strayEnvWarn != nil {
+		for _, v := range stray {
+			cfg.strayEnvWarn(v)
+		}
+	}
+	if cfg.strayEnvErrors && len(stray) > 0 {
+		return &StrayEnvError{Vars: stray}
+	}
+	return nil
+}
+
+// strayEnv lists the variables under prefix that no field of structType reads.
+func strayEnv(structType reflect.Type, prefix string) []StrayEnv {
+	fields := envFields(structType, prefix, "")
+
+	var stray []StrayEnv
+	for _, name := range envNames(prefix + "_") {
+		if !readsEnv(fields, name) {
+			stray = append(stray, StrayEnv{Key: name, Suggestion: suggestEnvKey(fields, name)})
+		}
+	}
+	return stray
+}
+
+// readsEnv reports whether name is one of fields or the unset variable of
+// one, or addresses an element of an indexed field the way
+// setCollectionFromEnv does.
+func readsEnv(fields []envField, name string) bool {
+	for _, field := range fields {
+		if name == field.key+envUnsetSuffix {
+			return true
+		}
+		if field.group {
+			continue
+		}
+		if !field.indexed {
+			if name == field.key {
+				return true
+			}
+			continue
+		}
+
+		rest, ok := strings.CutPrefix(name, field.key+"_")
+		if !ok {
+			continue
+		}
+		elemFields := envFields(structElem(field.collection), "", "")
+		for i := 1; i < len(rest); i++ {
+			if rest[i] != '_' || !readsEnv(elemFields, rest[i+1:]) {
+				continue
+			}
+			if field.collection.Kind() == reflect.Map {
+				return true
+			}
+			if index, err := strconv.Atoi(rest[:i]); err == nil && index >= 0 && strconv.Itoa(index) == rest[:i] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// suggestEnvKey returns the key of fields closest to name. Below an indexed
+// field, the index or map key is kept and only the element key is corrected.
+func suggestEnvKey(fields []envField, name string) string {
+	keys := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field.group {
+			continue
+		}
+		keys = append(keys, field.key)
+		if !field.indexed {
+			continue
+		}
+
+		rest, ok := strings.CutPrefix(name, field.key+"_")
+		if !ok {
+			continue
+		}
+		if segment, elemKey, ok := strings.Cut(rest, "_"); ok {
+			elemFields := envFields(structElem(field.collection), "", "")
+			if suggestion := suggestEnvKey(elemFields, elemKey); suggestion != "" {
+				return field.key + "_" + segment + "_" + suggestion
+			}
+		}
+	}
+	if suggestion := closestName(keys, name); suggestion != name {
+		return suggestion
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package konfig
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type strayConfig struct {
+	Name     string
+	Database struct {
+		Port int
+	}
+	Token     string `env:"-"`
+	Upstreams []envUpstream
+	DBs       map[string]envDB
+}
+
+func TestWithStrayEnvErrors(t *testing.T) {
+	t.Setenv("APP_NAME", "svc")
+	t.Setenv("APP_DATBASE_PORT", "1")
+	t.Setenv("APP_UPSTREAMS_0_HOST", "a")
+	t.Setenv("APP_UPSTREAMS_0_HOTS", "b")
+	t.Setenv("APP_UPSTREAMS_X_HOST", "c")
+	t.Setenv("APP_DBS_REPORTING_TLS_CERT", "cert.pem")
+	t.Setenv("APP_TOKEN", "secret")
+	t.Setenv("APPLICATION", "unrelated")
+
+	var c strayConfig
+	err := Load(&c, WithEnvPrefix("APP"), WithStrayEnvErrors())
+
+	var stray *StrayEnvError
+	if !errors.As(err, &stray) {
+		t.Fatalf("expected StrayEnvError, got %v", err)
+	}
+	want := []StrayEnv{
+		{Key: "APP_DATBASE_PORT", Suggestion: "APP_DATABASE_PORT"},
+		{Key: "APP_TOKEN"},
+		{Key: "APP_UPSTREAMS_0_HOTS", Suggestion: "APP_UPSTREAMS_0_HOST"},
+		{Key: "APP_UPSTREAMS_X_HOST"},
+	}
+	if !reflect.DeepEqual(stray.Vars, want) {
+		t.Fatalf("unexpected stray vars:\n got %+v\nwant %+v", stray.Vars, want)
+	}
+
+	wantMsg := "konfig: unused environment variables: APP_DATBASE_PORT (did you mean APP_DATABASE_PORT?), APP_TOKEN, " +
+		"APP_UPSTREAMS_0_HOTS (did you mean APP_UPSTREAMS_0_HOST?), APP_UPSTREAMS_X_HOST"
+	if err.Error() != wantMsg {
+		t.Fatalf("unexpected message:\n got %s\nwant %s", err.Error(), wantMsg)
+	}
+}
+
+func TestWithStrayEnvWarnings(t *testing.T) {
+	t.Setenv("APP_NAME", "svc")
+	t.Setenv("APP_NAEM", "typo")
+
+	var warnings []StrayEnv
+	var c strayConfig
+	if err := Load(&c, WithEnvPrefix("APP"), WithStrayEnvWarnings(func(v StrayEnv) { warnings = append(warnings, v) })); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Name != "svc" || len(warnings) != 1 || warnings[0].String() != "APP_NAEM (did you mean APP_NAME?)" {
+		t.Fatalf("unexpected warnings %v for %+v", warnings, c)
+	}
+
+	warnings = nil
+	t.Setenv("NAME", "svc")
+	if err := Load(&c, WithStrayEnvWarnings(func(v StrayEnv) { warnings = append(warnings, v) })); err != nil || warnings != nil {
+		t.Fatalf("expected no check without a prefix, got %v (%v)", warnings, err)
+	}
+}
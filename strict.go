@@ -0,0 +1,105 @@
+package konfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// WithStrict makes Load fail when a configuration file, or a tree merged by a
+// Source, contains keys that match no struct field. Keys holding maps or
+// interface{} values may contain anything.
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// UnknownKey is a key that matched no struct field in strict mode.
+type UnknownKey struct {
+	// Source is the file path or Source name the key was read from.
+	Source string
+	// Key is the dotted key path as written in the file, e.g.
+	// "database.prot" or "upstreams.0.hots".
+	Key string
+	// Line is the 1-based line of the key, or 0 when unknown.
+	Line int
+	// Suggestion is the closest known key at the same level, or empty if
+	// none is close enough.
+	Suggestion string
+}
+
+// UnknownKeyError reports every unknown key found by WithStrict.
+type UnknownKeyError struct {
+	Keys []UnknownKey
+}
+
+func (e *UnknownKeyError) Error() string {
+	parts := make([]string, 0, len(e.Keys))
+	for _, key := range e.Keys {
+		part := key.Source
+		if key.Line > 0 {
+			part += ":" + strconv.Itoa(key.Line)
+		}
+		part += ": " + key.Key
+		if key.Suggestion != "" {
+			part += fmt.Sprintf(" (did you mean %s?)", key.Suggestion)
+		}
+		parts = append(parts, part)
+	}
+	return "konfig: unknown configuration keys: " + strings.Join(parts, "; ")
+}
+
+// suggestField returns the name of the field closest to key, if any is a
+// plausible typo of it.
+func suggestField(fields []fileField, key string) string {
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.name
+	}
+	return closestName(names, key)
+}
+
+// closestName returns the candidate closest to name by edit distance,
+// ignoring case, as long as the two are similar enough to be a plausible
+// typo.
+func closestName(candidates []string, name string) string {
+	best, bestDistance := "", -1
+	for _, candidate := range candidates {
+		distance := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+
+	length := utf8.RuneCountInString(name)
+	if bestDistance < 0 || bestDistance >= length || bestDistance > max(2, length/3) {
+		return ""
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
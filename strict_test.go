@@ -0,0 +1,90 @@
+package konfig
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type strictConfig struct {
+	Name     string `json:"name" toml:"name"`
+	Database struct {
+		Port int `json:"port" toml:"port"`
+	} `json:"database" toml:"database"`
+	Upstreams []struct {
+		Host string `json:"host" toml:"host"`
+	} `json:"upstreams" toml:"upstreams"`
+	Labels map[string]string `json:"labels" toml:"labels"`
+	Extra  interface{}       `json:"extra" toml:"extra"`
+}
+
+func TestWithStrictReportsUnknownKeys(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "app.yaml")
+	tomlFile := filepath.Join(dir, "override.toml")
+	mustWrite(t, yamlFile, "name: svc\ndatabse:\n  port: 1\nupstreams:\n  - hots: a\nlabels:\n  anything: goes\nextra:\n  free: form\n")
+	mustWrite(t, tomlFile, "[database]\nprot = 5432\nzzzzzz = 1\n")
+
+	var c strictConfig
+	err := Load(&c, WithFiles(yamlFile, tomlFile), WithStrict())
+
+	var unknown *UnknownKeyError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("expected UnknownKeyError, got %v", err)
+	}
+
+	want := []UnknownKey{
+		{Source: yamlFile, Key: "databse", Line: 2, Suggestion: "database"},
+		{Source: yamlFile, Key: "upstreams.0.hots", Line: 5, Suggestion: "host"},
+		{Source: tomlFile, Key: "database.prot", Line: 2, Suggestion: "port"},
+		{Source: tomlFile, Key: "database.zzzzzz", Line: 3},
+	}
+	if !reflect.DeepEqual(unknown.Keys, want) {
+		t.Fatalf("unexpected unknown keys:\n got %+v\nwant %+v", unknown.Keys, want)
+	}
+
+	wantMsg := "konfig: unknown configuration keys: " + yamlFile + ":2: databse (did you mean database?); " +
+		yamlFile + ":5: upstreams.0.hots (did you mean host?); " +
+		tomlFile + ":2: database.prot (did you mean port?); " +
+		tomlFile + ":3: database.zzzzzz"
+	if err.Error() != wantMsg {
+		t.Fatalf("unexpected message:\n got %s\nwant %s", err.Error(), wantMsg)
+	}
+
+	if c.Name != "" {
+		t.Fatalf("expected config untouched on strict failure, got %+v", c)
+	}
+	if err := Load(&c, WithFiles(yamlFile, tomlFile)); err != nil {
+		t.Fatalf("expected unknown keys ignored without WithStrict, got %v", err)
+	}
+}
+
+func TestWithStrictCoversMergedTrees(t *testing.T) {
+	var c strictConfig
+	err := Load(&c, WithStrict(), WithSources(remoteSource{"name": "svc", "nmae": "typo"}))
+
+	var unknown *UnknownKeyError
+	if !errors.As(err, &unknown) || len(unknown.Keys) != 1 || unknown.Keys[0] != (UnknownKey{Source: "remote", Key: "nmae", Suggestion: "name"}) {
+		t.Fatalf("expected merged tree checked, got %v", err)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"port", "", 4},
+		{"databse", "database", 1},
+		{"prot", "port", 2},
+		{"kitten", "sitting", 3},
+		{"héllo", "hello", 1},
+	}
+	for _, tc := range cases {
+		if got := editDistance(tc.a, tc.b); got != tc.want {
+			t.Fatalf("editDistance(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
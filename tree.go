@@ -0,0 +1,347 @@
+package konfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	formatJSON = "json"
+	formatTOML = "toml"
+	formatYAML = "yaml"
+)
+
+// origins records, per dotted field path, every source that supplied it in
+// the order they were applied, so the last entry is the winning one.
+type origins struct {
+	fields map[string][]Origin
+	// positions asks file sources to look up the line of each key, which
+	// costs a second pass over the file and is only done for provenance.
+	positions bool
+}
+
+func newOrigins() *origins {
+	return &origins{fields: map[string][]Origin{}}
+}
+
+func (o *origins) record(path string, origin Origin) {
+	if o != nil {
+		o.fields[path] = append(o.fields[path], origin)
+	}
+}
+
+// clear records that origin reset path to its zero value, which also clears
+// every field recorded beneath it.
+func (o *origins) clear(path string, origin Origin) {
+	if o == nil {
+		return
+	}
+	for key := range o.fields {
+		if strings.HasPrefix(key, path+".") {
+			o.fields[key] = append(o.fields[key], origin)
+		}
+	}
+	o.fields[path] = append(o.fields[path], origin)
+}
+
+// last returns the origin that last supplied path or, failing that, the
+// closest enclosing path that was recorded.
+func (o *origins) last(path string) (Origin, bool) {
+	if o == nil {
+		return Origin{}, false
+	}
+	for {
+		if chain := o.fields[path]; len(chain) > 0 {
+			return chain[len(chain)-1], true
+		}
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			return Origin{}, false
+		}
+		path = path[:i]
+	}
+}
+
+// source describes the source that last supplied path, or "" if none did.
+func (o *origins) source(path string) string {
+	if o == nil || len(o.fields[path]) == 0 {
+		return ""
+	}
+	chain := o.fields[path]
+	return chain[len(chain)-1].String()
+}
+
+// suppliedUnder reports whether path, or any field nested beneath it, was
+// supplied by a source other than defaults and not cleared since.
+func (o *origins) suppliedUnder(path string) bool {
+	if o == nil {
+		return false
+	}
+	for key, chain := range o.fields {
+		if last := chain[len(chain)-1]; last.Kind == OriginDefault || last.Unset {
+			continue
+		}
+		if key == path || strings.HasPrefix(key, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// fileField is a struct field as addressed by a key in a decoded file.
+type fileField struct {
+	name  string
+	path  string
+	index []int
+	field reflect.StructField
+	// quoted marks a `json:",string"` field, whose value is written as a
+	// JSON literal inside a string.
+	quoted bool
+}
+
+// decodeTree decodes data into a generic key/value tree. JSON and YAML numbers
+// are kept as json.Number so integers survive without float rounding.
+func decodeTree(format string, data []byte) (map[string]interface{}, error) {
+	switch format {
+	case formatTOML:
+		tree := map[string]interface{}{}
+		if err := toml.Unmarshal(data, &tree); err != nil {
+			return nil, err
+		}
+		return tree, nil
+	case formatYAML:
+		converted, err := yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		data = converted
+	}
+
+	var tree map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&tree); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("invalid data after top-level value")
+	}
+
+	return tree, nil
+}
+
+// jsonObjectKeys returns the keys of the JSON object at the dotted key path in
+// data, ordered by their last occurrence in the document the way
+// encoding/json applies them, or nil when the object cannot be found.
+func jsonObjectKeys(data []byte, path string) []string {
+	if data == nil {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	var keys []string
+	found := false
+	var walk func(current string) bool
+	walk = func(current string) bool {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+
+		switch token {
+		case json.Delim('{'):
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return false
+				}
+				name := fmt.Sprint(key)
+				if current == path {
+					keys = append(slices.DeleteFunc(keys, func(k string) bool { return k == name }), name)
+				}
+				if !walk(joinPath(current, name)) {
+					return false
+				}
+			}
+			if current == path {
+				found = true
+				return false
+			}
+		case json.Delim('['):
+			for i := 0; decoder.More(); i++ {
+				if !walk(joinPath(current, strconv.Itoa(i))) {
+					return false
+				}
+			}
+		default:
+			return true
+		}
+
+		_, err = decoder.Token()
+		return err == nil
+	}
+
+	walk("")
+	if !found {
+		return nil
+	}
+	return keys
+}
+
+// quotedField reports whether field carries the json tag's string option and
+// has a type encoding/json honours it for.
+func quotedField(field reflect.StructField) bool {
+	_, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if !slices.Contains(strings.Split(options, ","), "string") {
+		return false
+	}
+
+	t := field.Type
+	if t.Name() == "" && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// unquoteValue decodes the JSON literal a quoted field holds in a string, as
+// encoding/json does for the string tag option.
+func unquoteValue(value interface{}, t reflect.Type) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		if value == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("invalid use of ,string struct tag, trying to unmarshal unquoted value into %s", t)
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	var literal interface{}
+	err := decoder.Decode(&literal)
+	if err == nil {
+		if _, err = decoder.Token(); errors.Is(err, io.EOF) {
+			err = nil
+		} else {
+			err = errors.New("invalid data after value")
+		}
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	valid := false
+	switch literal.(type) {
+	case nil:
+		valid = true
+	case string:
+		valid = t.Kind() == reflect.String
+	case bool, json.Number:
+		valid = t.Kind() != reflect.String
+	}
+	if err != nil || !valid {
+		return nil, fmt.Errorf("invalid use of ,string struct tag, trying to unmarshal %q into %s", s, t)
+	}
+	return literal, nil
+}
+
+// fileFields lists the keys a decoder for format matches against structType.
+// A name in the konfig tag applies to every format; otherwise the json tag is
+// used, or the toml tag for TOML. Fields of untagged embedded structs are
+// promoted, and outer fields shadow promoted ones, mirroring encoding/json and
+// BurntSushi/toml.
+func fileFields(structType reflect.Type, format string) []fileField {
+	tagName := "json"
+	if format == formatTOML {
+		tagName = "toml"
+	}
+
+	var fields, promoted []fileField
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		tag := field.Tag.Get("konfig")
+		if name := strings.Split(tag, ",")[0]; name == "" {
+			tag = field.Tag.Get(tagName)
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "-" && !strings.Contains(tag, ",") {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for _, inner := range fileFields(embedded, format) {
+					inner.path = joinPath(field.Name, inner.path)
+					inner.index = append([]int{i}, inner.index...)
+					promoted = append(promoted, inner)
+				}
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, fileField{name: name, path: field.Name, index: []int{i}, field: field, quoted: format != formatTOML && quotedField(field)})
+	}
+
+	outer := len(fields)
+	for _, inner := range promoted {
+		shadowed := false
+		for _, field := range fields[:outer] {
+			if field.name == inner.name {
+				shadowed = true
+				break
+			}
+		}
+		if !shadowed {
+			fields = append(fields, inner)
+		}
+	}
+
+	return fields
+}
+
+// lookupFileField prefers an exact key match and falls back to a
+// case-insensitive one, as the JSON and TOML decoders do.
+func lookupFileField(fields []fileField, key string) (fileField, bool) {
+	for _, field := range fields {
+		if field.name == key {
+			return field, true
+		}
+	}
+	for _, field := range fields {
+		if strings.EqualFold(field.name, key) {
+			return field, true
+		}
+	}
+	return fileField{}, false
+}
@@ -0,0 +1,297 @@
+package konfig
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redacted replaces the value of fields marked `secret` in violations.
+const redacted = "<redacted>"
+
+// Violation describes a single `validate` tag constraint that a field failed.
+type Violation struct {
+	// Path is the dotted Go field path, e.g. "Server.Port".
+	Path string
+	// Constraint is the failed constraint as written in the tag, e.g. "min=1".
+	Constraint string
+	// Value is the offending value, or "<redacted>" for fields marked secret.
+	Value string
+	// Source names what supplied the value: a file path (followed by ":line"
+	// when WithProvenance is used), "env KEY", "default", or empty when the
+	// value was already present in the struct.
+	Source string
+}
+
+// ValidationError reports every `validate` tag constraint violated after all
+// sources were applied.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		detail := "value " + v.Value
+		if v.Source != "" {
+			detail += ", from " + v.Source
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s (%s)", v.Path, v.Constraint, detail))
+	}
+	return "konfig: invalid configuration: " + strings.Join(parts, "; ")
+}
+
+// constraint is one parsed entry of a `validate` tag.
+type constraint struct {
+	name string
+	arg  string
+}
+
+func (c constraint) String() string {
+	if c.arg == "" {
+		return c.name
+	}
+	return c.name + "=" + c.arg
+}
+
+// parseConstraints splits a `validate` tag such as "min=1,max=10" into its
+// constraints. Because regular expressions may contain commas, pattern
+// consumes the remainder of the tag and must come last.
+func parseConstraints(tag string) ([]constraint, error) {
+	var constraints []constraint
+
+	for rest := tag; rest != ""; {
+		part := rest
+		rest = ""
+		if !strings.HasPrefix(strings.TrimSpace(part), "pattern=") {
+			if idx := strings.Index(part, ","); idx >= 0 {
+				part, rest = part[:idx], part[idx+1:]
+			}
+		}
+
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, arg, _ := strings.Cut(part, "=")
+		switch name {
+		case "nonempty":
+			if arg != "" {
+				return nil, fmt.Errorf("constraint %q takes no argument", name)
+			}
+		case "min", "max", "len", "oneof", "pattern":
+			if arg == "" {
+				return nil, fmt.Errorf("constraint %q requires an argument", name)
+			}
+		default:
+			return nil, fmt.Errorf("unknown constraint %q", name)
+		}
+
+		constraints = append(constraints, constraint{name: name, arg: arg})
+	}
+
+	return constraints, nil
+}
+
+// checkConstraints evaluates the `validate` tag of every exported field and
+// returns the violations found. Malformed tags are reported as an error.
+func checkConstraints(structValue reflect.Value, seen *origins) ([]Violation, error) {
+	var violations []Violation
+	err := collectViolations(structValue, "", seen, &violations)
+	return violations, err
+}
+
+func collectViolations(structValue reflect.Value, path string, seen *origins, violations *[]Violation) error {
+	structType := structValue.Type()
+
+	for i := 0; i < structValue.NumField(); i++ {
+		fieldType := structType.Field(i)
+		if !fieldType.IsExported() {
+			continue
+		}
+
+		fieldValue := structValue.Field(i)
+		fieldPath := joinPath(path, fieldType.Name)
+
+		if tag, ok := fieldType.Tag.Lookup("validate"); ok {
+			constraints, err := parseConstraints(tag)
+			if err != nil {
+				return fmt.Errorf("konfig: validate tag on %s: %w", fieldPath, err)
+			}
+
+			for _, c := range constraints {
+				ok, err := satisfies(fieldValue, c)
+				if err != nil {
+					return fmt.Errorf("konfig: validate tag on %s: %w", fieldPath, err)
+				}
+				if ok {
+					continue
+				}
+
+				value := formatValue(fieldValue)
+				if hasTagOption(fieldType, "secret") {
+					value = redacted
+				}
+				*violations = append(*violations, Violation{
+					Path:       fieldPath,
+					Constraint: c.String(),
+					Value:      value,
+					Source:     seen.source(fieldPath),
+				})
+			}
+		}
+
+		if err := collectNestedViolations(fieldValue, fieldPath, seen, violations); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collectNestedViolations checks the fields of v when it is a struct or a
+// non-nil pointer to one, and of the elements of v when it is a slice, array
+// or map, reported under paths such as Ups.0.Port.
+func collectNestedViolations(v reflect.Value, path string, seen *origins, violations *[]Violation) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		return collectViolations(v, path, seen, violations)
+	}
+	return forEachElement(v, path, func(elem reflect.Value, elemPath string) error {
+		return collectNestedViolations(elem, elemPath, seen, violations)
+	})
+}
+
+// satisfies reports whether field meets c. Nil pointers only fail nonempty;
+// the remaining constraints apply to the value they point to once set.
+func satisfies(field reflect.Value, c constraint) (bool, error) {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return c.name != "nonempty", nil
+		}
+		field = field.Elem()
+	}
+
+	switch c.name {
+	case "nonempty":
+		if hasLength(field) {
+			return field.Len() > 0, nil
+		}
+		return !field.IsZero(), nil
+	case "min", "max":
+		return compareBound(field, c)
+	case "len":
+		if !hasLength(field) {
+			return false, fmt.Errorf("len does not apply to kind %s", field.Kind())
+		}
+		n, err := strconv.Atoi(c.arg)
+		if err != nil {
+			return false, fmt.Errorf("len: %w", err)
+		}
+		return field.Len() == n, nil
+	case "oneof":
+		value := formatScalar(field)
+		for _, option := range strings.Fields(c.arg) {
+			if option == value {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "pattern":
+		if field.Kind() != reflect.String {
+			return false, fmt.Errorf("pattern does not apply to kind %s", field.Kind())
+		}
+		re, err := regexp.Compile(c.arg)
+		if err != nil {
+			return false, fmt.Errorf("pattern: %w", err)
+		}
+		return re.MatchString(field.String()), nil
+	}
+
+	return false, fmt.Errorf("unknown constraint %q", c.name)
+}
+
+// compareBound checks min and max against numbers directly (durations are
+// written as "1s"), and against the length of strings, slices, arrays and maps.
+func compareBound(field reflect.Value, c constraint) (bool, error) {
+	within := func(order int) bool {
+		if c.name == "min" {
+			return order >= 0
+		}
+		return order <= 0
+	}
+
+	if field.Type() == durationType {
+		bound, err := time.ParseDuration(c.arg)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", c.name, err)
+		}
+		return within(cmp.Compare(field.Int(), int64(bound))), nil
+	}
+
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		bound, err := strconv.ParseInt(c.arg, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", c.name, err)
+		}
+		return within(cmp.Compare(field.Int(), bound)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		bound, err := strconv.ParseUint(c.arg, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", c.name, err)
+		}
+		return within(cmp.Compare(field.Uint(), bound)), nil
+	case reflect.Float32, reflect.Float64:
+		bound, err := strconv.ParseFloat(c.arg, 64)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", c.name, err)
+		}
+		return within(cmp.Compare(field.Float(), bound)), nil
+	}
+
+	if hasLength(field) {
+		bound, err := strconv.Atoi(c.arg)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", c.name, err)
+		}
+		return within(cmp.Compare(field.Len(), bound)), nil
+	}
+
+	return false, fmt.Errorf("%s does not apply to kind %s", c.name, field.Kind())
+}
+
+func hasLength(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	}
+	return false
+}
+
+func formatScalar(field reflect.Value) string {
+	return fmt.Sprint(field.Interface())
+}
+
+func formatValue(field reflect.Value) string {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return "nil"
+		}
+		field = field.Elem()
+	}
+	if field.Kind() == reflect.String {
+		return strconv.Quote(field.String())
+	}
+	return formatScalar(field)
+}
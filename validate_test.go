@@ -0,0 +1,173 @@
+package konfig
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadConstraintViolationsReportedTogether(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	mustWrite(t, file, "Level: trace\nName: Svc\nTags: []\n")
+
+	type cfg struct {
+		Port     int      `validate:"min=1,max=65535"`
+		Level    string   `validate:"oneof=debug info warn"`
+		Name     string   `validate:"pattern=^[a-z]{1,16}$"`
+		Tags     []string `validate:"nonempty"`
+		Password string   `konfig:",secret" validate:"len=12"`
+		Workers  int      `default:"0" validate:"min=1"`
+	}
+
+	t.Setenv("APP_PORT", "70000")
+	t.Setenv("APP_PASSWORD", "hunter2")
+
+	var c cfg
+	err := Load(&c, WithFiles(file), WithEnvPrefix("APP"))
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+
+	want := []Violation{
+		{Path: "Port", Constraint: "max=65535", Value: "70000", Source: "env APP_PORT"},
+		{Path: "Level", Constraint: "oneof=debug info warn", Value: `"trace"`, Source: file},
+		{Path: "Name", Constraint: "pattern=^[a-z]{1,16}$", Value: `"Svc"`, Source: file},
+		{Path: "Tags", Constraint: "nonempty", Value: "[]", Source: file},
+		{Path: "Password", Constraint: "len=12", Value: redacted, Source: "env APP_PASSWORD"},
+		{Path: "Workers", Constraint: "min=1", Value: "0", Source: "default"},
+	}
+	if !reflect.DeepEqual(verr.Violations, want) {
+		t.Fatalf("unexpected violations:\n got %#v\nwant %#v", verr.Violations, want)
+	}
+
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Fatalf("expected secret value to be redacted, got %v", err)
+	}
+}
+
+func TestLoadConstraintsOnCollectionElements(t *testing.T) {
+	type up struct {
+		Host string `validate:"nonempty"`
+		Port int    `validate:"min=1"`
+	}
+	type cfg struct {
+		Ups     []up
+		Backups []*up
+		DBs     map[string]up
+		Pairs   [1]up
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	mustWrite(t, file, "ups:\n  - host: h\n    port: 80\n  - host: h\nbackups: [null, {host: b, port: 1}]\ndbs:\n  main: {host: db}\n")
+	t.Setenv("APP_UPS_0_PORT", "0")
+
+	var c cfg
+	err := Load(&c, WithFiles(file), WithEnvPrefix("APP"))
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	want := []Violation{
+		{Path: "Ups.0.Port", Constraint: "min=1", Value: "0", Source: "env APP_UPS_0_PORT"},
+		{Path: "Ups.1.Port", Constraint: "min=1", Value: "0", Source: ""},
+		{Path: "DBs.main.Port", Constraint: "min=1", Value: "0", Source: ""},
+		{Path: "Pairs.0.Host", Constraint: "nonempty", Value: `""`, Source: ""},
+		{Path: "Pairs.0.Port", Constraint: "min=1", Value: "0", Source: ""},
+	}
+	if !reflect.DeepEqual(verr.Violations, want) {
+		t.Fatalf("unexpected violations:\n got %#v\nwant %#v", verr.Violations, want)
+	}
+}
+
+func TestLoadConstraintsPass(t *testing.T) {
+	type nested struct {
+		Host string `validate:"nonempty"`
+	}
+
+	type cfg struct {
+		Port    *int    `validate:"min=1"`
+		Ratio   float64 `validate:"min=0.5,max=1"`
+		Retries uint    `validate:"max=5"`
+		Level   string  `validate:"oneof=debug info"`
+		Nested  nested
+		Missing *nested
+	}
+
+	t.Setenv("APP_RATIO", "0.75")
+	t.Setenv("APP_RETRIES", "3")
+	t.Setenv("APP_LEVEL", "info")
+	t.Setenv("APP_NESTED_HOST", "db")
+
+	var c cfg
+	if err := Load(&c, WithEnvPrefix("APP")); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+}
+
+func TestLoadConstraintsCombinedWithValidators(t *testing.T) {
+	t.Setenv("APP_NAME", "")
+
+	var c struct {
+		Name    string `validate:"nonempty"`
+		Invalid hookedCache
+	}
+	c.Invalid.Size = -1
+
+	err := Load(&c, WithEnvPrefix("APP"))
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Violations) != 1 {
+		t.Fatalf("expected one constraint violation, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "validate Invalid: size must not be negative") {
+		t.Fatalf("expected validator error alongside constraints, got %v", err)
+	}
+}
+
+func TestLoadConstraintTagErrors(t *testing.T) {
+	t.Setenv("APP_VALUE", "1")
+
+	cases := []struct {
+		name   string
+		config interface{}
+		want   string
+	}{
+		{"unknown", &struct {
+			Value int `validate:"positive"`
+		}{}, `unknown constraint "positive"`},
+		{"missing argument", &struct {
+			Value int `validate:"min"`
+		}{}, `constraint "min" requires an argument`},
+		{"bad bound", &struct {
+			Value int `validate:"min=one"`
+		}{}, "min: strconv.ParseInt"},
+		{"bad pattern", &struct {
+			Value string `validate:"pattern=("`
+		}{}, "pattern: error parsing regexp"},
+		{"pattern on int", &struct {
+			Value int `validate:"pattern=^1$"`
+		}{}, "pattern does not apply to kind int"},
+		{"len on bool", &struct {
+			Value bool `validate:"len=1"`
+		}{}, "len does not apply to kind bool"},
+		{"nonempty argument", &struct {
+			Value int `validate:"nonempty=1"`
+		}{}, `constraint "nonempty" takes no argument`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Load(tc.config, WithEnvPrefix("APP"))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
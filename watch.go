@@ -0,0 +1,250 @@
+package konfig
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultPollInterval is how often Watch checks its files unless
+// WithPollInterval says otherwise.
+const defaultPollInterval = time.Second
+
+// WithPollInterval sets how often Watch checks its files for changes. Zero
+// keeps the default of one second, and Watch rejects a negative interval.
+// Load ignores it.
+func WithPollInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.pollInterval = interval
+	}
+}
+
+// Store holds the most recently loaded configuration of type T and reloads it
+// when the files it was loaded from change. It is safe for concurrent use.
+type Store[T any] struct {
+	opts     []Option
+	files    []string
+	interval time.Duration
+	// included lists the files pulled in by $include directives during the
+	// last load, guarded by mu.
+	included []string
+
+	current atomic.Pointer[T]
+
+	// reloadMu serializes reloads so callbacks observe them in order.
+	reloadMu sync.Mutex
+
+	mu       sync.Mutex
+	err      error
+	onChange []func(old, new T)
+	onError  []func(error)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Watch loads a T with the same options Load accepts and then polls the files
+// its sources read from the operating system (those named by WithBase and
+// WithFiles, or by a FileSource or FirstOfSource, and the files they
+// include), reloading whenever one of them is created, modified or removed.
+// Every reload decodes into a fresh T, so a reload that fails to decode or
+// validate leaves the last good configuration in place and is reported
+// through Err and OnError instead. Other sources, including environment
+// variables, are read again on every reload but are not watched themselves.
+//
+// Watching stops when ctx is done or Close is called. T must be a struct type.
+func Watch[T any](ctx context.Context, opts ...Option) (*Store[T], error) {
+	cfg := options{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.pollInterval < 0 {
+		return nil, fmt.Errorf("konfig: poll interval must not be negative, got %s", cfg.pollInterval)
+	}
+
+	s := &Store[T]{
+		opts:     opts,
+		files:    watchedFiles(cfg),
+		interval: cmp.Or(cfg.pollInterval, defaultPollInterval),
+		done:     make(chan struct{}),
+	}
+
+	// Stamp the files before loading so a change made during the initial
+	// load still triggers a reload.
+	stamps := s.stamp()
+
+	value := new(T)
+	if err := s.load(value); err != nil {
+		return nil, err
+	}
+	s.current.Store(value)
+	for file, stamp := range s.stamp() {
+		if _, ok := stamps[file]; !ok {
+			stamps[file] = stamp
+		}
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	go s.watch(ctx, stamps)
+
+	return s, nil
+}
+
+// Get returns the current configuration. Each reload produces a new value, so
+// the returned snapshot is never modified by a later reload.
+func (s *Store[T]) Get() T {
+	return *s.current.Load()
+}
+
+// Err returns the error of the most recent reload, or nil if it succeeded.
+func (s *Store[T]) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// OnChange registers fn to be called after a reload replaces the
+// configuration with a different value. Callbacks run in registration order
+// on the goroutine that performed the reload.
+func (s *Store[T]) OnChange(fn func(old, new T)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = append(s.onChange, fn)
+}
+
+// OnError registers fn to be called when a reload fails. The previous
+// configuration stays in effect.
+func (s *Store[T]) OnError(fn func(error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onError = append(s.onError, fn)
+}
+
+// Reload loads the configuration again immediately, as if a watched file had
+// changed.
+func (s *Store[T]) Reload() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	next := new(T)
+	err := s.load(next)
+
+	s.mu.Lock()
+	s.err = err
+	onChange := s.onChange
+	onError := s.onError
+	s.mu.Unlock()
+
+	if err != nil {
+		for _, fn := range onError {
+			fn(err)
+		}
+		return err
+	}
+
+	old := s.current.Swap(next)
+	if !reflect.DeepEqual(*old, *next) {
+		for _, fn := range onChange {
+			fn(*old, *next)
+		}
+	}
+	return nil
+}
+
+// load runs Load with the store's options, noting the files it included. A
+// failed load keeps watching the files included before as well.
+func (s *Store[T]) load(value *T) error {
+	var included []string
+	opts := append(slices.Clip(s.opts), withIncludeHook(func(name string) {
+		included = append(included, name)
+	}))
+	err := Load(value, opts...)
+
+	s.mu.Lock()
+	if err != nil {
+		included = append(included, s.included...)
+	}
+	s.included = included
+	s.mu.Unlock()
+	return err
+}
+
+// Close stops watching and waits for an in-flight reload to finish.
+func (s *Store[T]) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+func (s *Store[T]) watch(ctx context.Context, stamps map[string]fileStamp) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			next := s.stamp()
+			if maps.Equal(next, stamps) {
+				continue
+			}
+			stamps = next
+			_ = s.Reload()
+		}
+	}
+}
+
+// fileStamp is what polling compares to notice that a file changed. The zero
+// value stands for a missing file.
+type fileStamp struct {
+	modTime int64
+	size    int64
+}
+
+func (s *Store[T]) stamp() map[string]fileStamp {
+	s.mu.Lock()
+	files := append(slices.Clip(s.files), s.included...)
+	s.mu.Unlock()
+
+	stamps := make(map[string]fileStamp, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			stamps[file] = fileStamp{}
+			continue
+		}
+		stamps[file] = fileStamp{modTime: info.ModTime().UnixNano(), size: info.Size()}
+	}
+	return stamps
+}
+
+// watchedFiles lists every file the sources of cfg may read from the
+// operating system, including candidates that do not exist yet.
+func watchedFiles(cfg options) []string {
+	sources, _ := cfg.pipeline()
+
+	var files []string
+	for _, source := range sources {
+		watched, ok := source.(interface{ watchFiles() []string })
+		if !ok {
+			continue
+		}
+		for _, file := range watched.watchFiles() {
+			if file = strings.TrimSpace(file); file != "" {
+				files = append(files, file)
+			}
+		}
+	}
+	return files
+}
@@ -0,0 +1,146 @@
+package konfig
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type watchConfig struct {
+	Name    string
+	Workers int `validate:"min=1"`
+}
+
+func TestWatchReloadsOnChange(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.json")
+	mustWrite(t, file, `{"Name":"a","Workers":1}`)
+
+	store, err := Watch[watchConfig](context.Background(), WithFiles(file), WithPollInterval(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Watch returned error: %v", err)
+	}
+	defer store.Close()
+
+	if got := store.Get(); got.Name != "a" || got.Workers != 1 {
+		t.Fatalf("unexpected initial config %+v", got)
+	}
+
+	changes := make(chan [2]watchConfig, 1)
+	store.OnChange(func(old, new watchConfig) {
+		changes <- [2]watchConfig{old, new}
+	})
+	errs := make(chan error, 1)
+	store.OnError(func(err error) {
+		errs <- err
+	})
+
+	mustWrite(t, file, `{"Name":"b","Workers":2}`)
+	select {
+	case change := <-changes:
+		if change[0].Name != "a" || change[1].Name != "b" || change[1].Workers != 2 {
+			t.Fatalf("unexpected change %+v", change)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for reload")
+	}
+	if got := store.Get(); got.Name != "b" || store.Err() != nil {
+		t.Fatalf("expected reloaded config, got %+v (%v)", got, store.Err())
+	}
+
+	mustWrite(t, file, `{"Name":"invalid","Workers":0}`)
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "Workers: min=1") {
+			t.Fatalf("unexpected reload error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for reload error")
+	}
+	if got := store.Get(); got.Name != "b" || got.Workers != 2 {
+		t.Fatalf("expected last good config kept, got %+v", got)
+	}
+	if store.Err() == nil {
+		t.Fatalf("expected Err to report the failed reload")
+	}
+}
+
+func TestWatchPicksUpCreatedBaseFile(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app")
+	t.Setenv("APP_WORKERS", "1")
+
+	store, err := Watch[watchConfig](context.Background(), WithBase(base), WithEnvPrefix("APP"), WithPollInterval(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Watch returned error: %v", err)
+	}
+	defer store.Close()
+
+	changed := make(chan watchConfig, 1)
+	store.OnChange(func(_, new watchConfig) {
+		changed <- new
+	})
+
+	mustWrite(t, base+".yaml", "Name: file\nWorkers: 4\n")
+	select {
+	case got := <-changed:
+		if got.Name != "file" || got.Workers != 1 {
+			t.Fatalf("expected file layered under env, got %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for reload")
+	}
+}
+
+func TestStoreReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+	mustWrite(t, file, "Name = \"a\"\nWorkers = 1\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	store, err := Watch[watchConfig](ctx, WithFiles(file), WithPollInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("Watch returned error: %v", err)
+	}
+
+	var calls int
+	store.OnChange(func(_, _ watchConfig) { calls++ })
+
+	if err := store.Reload(); err != nil || calls != 0 {
+		t.Fatalf("expected unchanged reload to skip callbacks, got %d calls (%v)", calls, err)
+	}
+
+	mustWrite(t, file, "Name = \"b\"\nWorkers = 1\n")
+	if err := store.Reload(); err != nil || calls != 1 || store.Get().Name != "b" {
+		t.Fatalf("expected manual reload to apply, got %+v, %d calls (%v)", store.Get(), calls, err)
+	}
+
+	if err := os.Remove(file); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if err := store.Reload(); err != ErrNoSources || store.Get().Name != "b" {
+		t.Fatalf("expected ErrNoSources with last config kept, got %+v (%v)", store.Get(), err)
+	}
+
+	cancel()
+	store.Close()
+}
+
+func TestWatchInitialLoadError(t *testing.T) {
+	if _, err := Watch[watchConfig](context.Background(), WithFiles(filepath.Join(t.TempDir(), "missing.json"))); err != ErrNoSources {
+		t.Fatalf("expected ErrNoSources, got %v", err)
+	}
+}
+
+func TestWatchRejectsNegativePollInterval(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	mustWrite(t, file, `{"Name":"a"}`)
+
+	_, err := Watch[watchConfig](context.Background(), WithFiles(file), WithPollInterval(-time.Second))
+	if err == nil || err.Error() != "konfig: poll interval must not be negative, got -1s" {
+		t.Fatalf("expected negative interval rejected, got %v", err)
+	}
+}